@@ -0,0 +1,360 @@
+package brlyt
+
+import (
+	"math"
+	"slices"
+)
+
+// animatedPane gives access to the animatable values shared by every pane type.
+type animatedPane struct {
+	flag      *uint8
+	visible   *uint8
+	alpha     *uint8
+	translate *Coord3D
+	rotate    *Coord3D
+	scale     *Coord2D
+	width     *float32
+	height    *float32
+	// colors are the top left, top right, bottom left and bottom right vertex colours.
+	colors [4]*Color8
+}
+
+// EvaluateAnimation returns a copy of layout with the state anim gives it at frame.
+// Frames wrap around the animation's length if it loops. layout is not modified.
+func EvaluateAnimation(layout *Root, anim *Animation, frame float32) *Root {
+	root := layout.clone()
+
+	if anim.Loop != 0 && anim.FrameSize != 0 {
+		frame = float32(math.Mod(float64(frame), float64(anim.FrameSize)))
+		if frame < 0 {
+			frame += float32(anim.FrameSize)
+		}
+	}
+
+	var textures []string
+	if anim.Textures != nil {
+		textures = anim.Textures.TPLName
+	}
+
+	panes := root.animatedPanes()
+	for _, entry := range anim.Entries {
+		switch entry.Type {
+		case AnimationTypePane:
+			pane, ok := panes[entry.Name]
+			if !ok {
+				continue
+			}
+
+			for _, tag := range entry.Tags {
+				for _, target := range tag.Targets {
+					if len(target.Keys) == 0 {
+						continue
+					}
+
+					pane.apply(tag.Type, target, evaluateCurve(target, frame))
+				}
+			}
+		case AnimationTypeMaterial:
+			index := slices.IndexFunc(root.MAT.Entries, func(mat MATEntries) bool {
+				return mat.Name == entry.Name
+			})
+
+			if index == -1 {
+				continue
+			}
+
+			material := &root.MAT.Entries[index]
+			for _, tag := range entry.Tags {
+				for _, target := range tag.Targets {
+					if len(target.Keys) == 0 {
+						continue
+					}
+
+					material.apply(tag.Type, target, evaluateCurve(target, frame), textures)
+				}
+			}
+		}
+	}
+
+	return root
+}
+
+// evaluateCurve returns the value of the target's curve at frame. Frames outside
+// of the keys hold the value of the nearest key.
+func evaluateCurve(target XMLAnimationTarget, frame float32) float32 {
+	keys := target.Keys
+	if frame <= keys[0].Frame {
+		return keys[0].Value
+	}
+
+	last := keys[len(keys)-1]
+	if frame >= last.Frame {
+		return last.Value
+	}
+
+	// Find the keys on either side of frame.
+	next := 1
+	for keys[next].Frame <= frame {
+		next++
+	}
+
+	key0, key1 := keys[next-1], keys[next]
+	if target.CurveType == CurveTypeStep {
+		return key0.Value
+	}
+
+	duration := key1.Frame - key0.Frame
+	t := (frame - key0.Frame) / duration
+	t2 := t * t
+	t3 := t2 * t
+
+	// Cubic Hermite basis functions, with the slopes scaled to the key interval.
+	h1 := 2*t3 - 3*t2 + 1
+	h2 := -2*t3 + 3*t2
+	h3 := t3 - 2*t2 + t
+	h4 := t3 - t2
+
+	return h1*key0.Value + h2*key1.Value + (h3*key0.Slope+h4*key1.Slope)*duration
+}
+
+func (p animatedPane) apply(tag string, target XMLAnimationTarget, value float32) {
+	switch tag {
+	case AnimationTagPaneSRT:
+		switch target.Target {
+		case TargetTranslateX:
+			p.translate.X = value
+		case TargetTranslateY:
+			p.translate.Y = value
+		case TargetTranslateZ:
+			p.translate.Z = value
+		case TargetRotateX:
+			p.rotate.X = value
+		case TargetRotateY:
+			p.rotate.Y = value
+		case TargetRotateZ:
+			p.rotate.Z = value
+		case TargetScaleX:
+			p.scale.X = value
+		case TargetScaleY:
+			p.scale.Y = value
+		case TargetWidth:
+			*p.width = value
+		case TargetHeight:
+			*p.height = value
+		}
+	case AnimationTagVisibility:
+		if target.Target != 0 {
+			return
+		}
+
+		var visible uint8
+		if value != 0 {
+			visible = 1
+		}
+
+		*p.flag = *p.flag&^1 | visible
+		if p.visible != nil {
+			*p.visible = visible
+		}
+	case AnimationTagVertexColor:
+		if target.Target == TargetPaneAlpha {
+			*p.alpha = clampColor8(value)
+			return
+		}
+
+		if target.Target >= TargetPaneAlpha || p.colors[target.Target/4] == nil {
+			return
+		}
+
+		setComponent8(p.colors[target.Target/4], target.Target%4, value)
+	}
+}
+
+func (m *MATEntries) apply(tag string, target XMLAnimationTarget, value float32, textures []string) {
+	switch tag {
+	case AnimationTagMaterialColor:
+		component := target.Target % 4
+		switch {
+		case target.Target < TargetColorRegister:
+			// Giving a material a colour it lacks would change its layout when the
+			// evaluated root is written, so those keep the default white.
+			if m.MatColor != nil {
+				setComponent8(m.MatColor, component, value)
+			}
+		case target.Target < TargetKonstColor:
+			registers := []*Color16{&m.ForeColor, &m.BackColor, &m.ColorReg3}
+			setComponent16(registers[(target.Target-TargetColorRegister)/4], component, value)
+		case target.Target < numMaterialColorTargets:
+			konst := []*Color8{&m.TevColor1, &m.TevColor2, &m.TevColor3, &m.TevColor4}
+			setComponent8(konst[(target.Target-TargetKonstColor)/4], component, value)
+		}
+	case AnimationTagTextureSRT:
+		if int(target.Index) < len(m.SRT) {
+			m.SRT[target.Index].apply(target.Target, value)
+		}
+	case AnimationTagIndirectSRT:
+		if int(target.Index) < len(m.IndirectSRT) {
+			m.IndirectSRT[target.Index].apply(target.Target, value)
+		}
+	case AnimationTagTexturePattern:
+		image := int(value)
+		if int(target.Index) < len(m.Textures) && image >= 0 && image < len(textures) {
+			m.Textures[target.Index].Name = textures[image]
+		}
+	}
+}
+
+func (s *MATSRT) apply(target uint8, value float32) {
+	switch target {
+	case TargetSRTTranslateS:
+		s.XTrans = value
+	case TargetSRTTranslateT:
+		s.YTrans = value
+	case TargetSRTRotate:
+		s.Rotation = value
+	case TargetSRTScaleS:
+		s.XScale = value
+	case TargetSRTScaleT:
+		s.YScale = value
+	}
+}
+
+func setComponent8(color *Color8, component uint8, value float32) {
+	channels := []*uint8{&color.R, &color.G, &color.B, &color.A}
+	*channels[component] = clampColor8(value)
+}
+
+func setComponent16(color *Color16, component uint8, value float32) {
+	channels := []*int16{&color.R, &color.G, &color.B, &color.A}
+	*channels[component] = int16(math.Round(math.Max(-1024, math.Min(1023, float64(value)))))
+}
+
+func clampColor8(value float32) uint8 {
+	return uint8(math.Round(math.Max(0, math.Min(255, float64(value)))))
+}
+
+// animatedPanes returns the animatable values of every pane in the layout by name.
+func (r *Root) animatedPanes() map[string]animatedPane {
+	panes := map[string]animatedPane{}
+	pane := &r.RootPane
+	panes[pane.Name] = animatedPane{
+		flag: &pane.Flag, alpha: &pane.Alpha,
+		translate: &pane.Translate, rotate: &pane.Rotate, scale: &pane.Scale,
+		width: &pane.Width, height: &pane.Height,
+	}
+
+	addAnimatedPanes(panes, r.RootPane.Children)
+	return panes
+}
+
+func addAnimatedPanes(panes map[string]animatedPane, children []Children) {
+	for _, child := range children {
+		switch {
+		case child.Pane != nil, child.BND != nil:
+			pane := child.Pane
+			if pane == nil {
+				pane = child.BND
+			}
+
+			panes[pane.Name] = animatedPane{
+				flag: &pane.Flag, alpha: &pane.Alpha,
+				translate: &pane.Translate, rotate: &pane.Rotate, scale: &pane.Scale,
+				width: &pane.Width, height: &pane.Height,
+			}
+
+			addAnimatedPanes(panes, pane.Children)
+		case child.PIC != nil:
+			pic := child.PIC
+			panes[pic.Name] = animatedPane{
+				flag: &pic.Flag, visible: &pic.Visible, alpha: &pic.Alpha,
+				translate: &pic.Translate, rotate: &pic.Rotate, scale: &pic.Scale,
+				width: &pic.Width, height: &pic.Height,
+				colors: [4]*Color8{&pic.TopLeftColor, &pic.TopRightColor, &pic.BottomLeftColor, &pic.BottomRightColor},
+			}
+
+			addAnimatedPanes(panes, pic.Children)
+		case child.TXT != nil:
+			// Text panes only have a top and a bottom colour.
+			txt := child.TXT
+			panes[txt.Name] = animatedPane{
+				flag: &txt.Flag, visible: &txt.Visible, alpha: &txt.Alpha,
+				translate: &txt.Translate, rotate: &txt.Rotate, scale: &txt.Scale,
+				width: &txt.Width, height: &txt.Height,
+				colors: [4]*Color8{&txt.TopColor, &txt.TopColor, &txt.BottomColor, &txt.BottomColor},
+			}
+
+			addAnimatedPanes(panes, txt.Children)
+		case child.WND != nil:
+			wnd := child.WND
+			panes[wnd.Name] = animatedPane{
+				flag: &wnd.Flag, visible: &wnd.Visible, alpha: &wnd.Alpha,
+				translate: &wnd.Translate, rotate: &wnd.Rotate, scale: &wnd.Scale,
+				width: &wnd.Width, height: &wnd.Height,
+				colors: [4]*Color8{&wnd.TopLeftColor, &wnd.TopRightColor, &wnd.BottomLeftColor, &wnd.BottomRightColor},
+			}
+
+			addAnimatedPanes(panes, wnd.Children)
+		}
+	}
+}
+
+// clone returns a copy of the layout that shares nothing an animation can change.
+func (r *Root) clone() *Root {
+	root := *r
+	root.reader = nil
+	root.path = nil
+
+	root.MAT.Entries = slices.Clone(r.MAT.Entries)
+	for i := range root.MAT.Entries {
+		entry := &root.MAT.Entries[i]
+		entry.Textures = slices.Clone(entry.Textures)
+		entry.SRT = slices.Clone(entry.SRT)
+		entry.IndirectSRT = slices.Clone(entry.IndirectSRT)
+		if entry.MatColor != nil {
+			matColor := *entry.MatColor
+			entry.MatColor = &matColor
+		}
+	}
+
+	root.RootPane.Children = cloneChildren(r.RootPane.Children)
+	return &root
+}
+
+func cloneChildren(children []Children) []Children {
+	if children == nil {
+		return nil
+	}
+
+	cloned := make([]Children, len(children))
+	for i, child := range children {
+		if child.Pane != nil {
+			pane := *child.Pane
+			pane.Children = cloneChildren(pane.Children)
+			child.Pane = &pane
+		}
+		if child.BND != nil {
+			bnd := *child.BND
+			bnd.Children = cloneChildren(bnd.Children)
+			child.BND = &bnd
+		}
+		if child.PIC != nil {
+			pic := *child.PIC
+			pic.Children = cloneChildren(pic.Children)
+			child.PIC = &pic
+		}
+		if child.TXT != nil {
+			txt := *child.TXT
+			txt.Children = cloneChildren(txt.Children)
+			child.TXT = &txt
+		}
+		if child.WND != nil {
+			wnd := *child.WND
+			wnd.Children = cloneChildren(wnd.Children)
+			child.WND = &wnd
+		}
+
+		cloned[i] = child
+	}
+
+	return cloned
+}
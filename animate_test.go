@@ -0,0 +1,103 @@
+package brlyt
+
+import (
+	"bytes"
+	"math"
+	"reflect"
+	"testing"
+)
+
+func TestEvaluateCurve(t *testing.T) {
+	step := XMLAnimationTarget{CurveType: CurveTypeStep, Keys: []Keyframe{
+		{Frame: 0, Value: 1},
+		{Frame: 10, Value: 2},
+		{Frame: 20, Value: 3},
+	}}
+
+	// Equal slopes of one make the curve a straight line.
+	linear := XMLAnimationTarget{CurveType: CurveTypeHermite, Keys: []Keyframe{
+		{Frame: 0, Value: 0, Slope: 1},
+		{Frame: 10, Value: 10, Slope: 1},
+	}}
+
+	flat := XMLAnimationTarget{CurveType: CurveTypeHermite, Keys: []Keyframe{
+		{Frame: 0, Value: 0},
+		{Frame: 10, Value: 10},
+	}}
+
+	tests := []struct {
+		name   string
+		target XMLAnimationTarget
+		frame  float32
+		want   float32
+	}{
+		{"step on a key", step, 10, 2},
+		{"step between keys", step, 19.5, 2},
+		{"step before the first key", step, -5, 1},
+		{"step after the last key", step, 25, 3},
+		{"hermite with slopes at a quarter", linear, 2.5, 2.5},
+		{"hermite with slopes halfway", linear, 5, 5},
+		{"hermite without slopes at a quarter", flat, 2.5, 1.5625},
+		{"hermite without slopes halfway", flat, 5, 5},
+		{"hermite before the first key", flat, -1, 0},
+		{"hermite after the last key", flat, 11, 10},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := evaluateCurve(test.target, test.frame)
+			if math.Abs(float64(got-test.want)) > 1e-4 {
+				t.Errorf("got %v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestEvaluateAnimation(t *testing.T) {
+	anim := testAnimation()
+	anim.Entries[1].Tags = append(anim.Entries[1].Tags, AnimationTag{
+		Type: AnimationTagMaterialColor,
+		Targets: []XMLAnimationTarget{{Target: 0, CurveType: CurveTypeStep, Keys: []Keyframe{
+			{Frame: 0, Value: 0},
+		}}},
+	})
+
+	layout := testLayout()
+	root := EvaluateAnimation(layout, anim, 60)
+
+	if !reflect.DeepEqual(layout, testLayout()) {
+		t.Error("evaluating the animation changed the input layout")
+	}
+
+	pic := root.RootPane.Children[0].PIC
+	if pic.Translate.X != 100 || pic.Rotate.Z != 180 || pic.Alpha != 0 {
+		t.Errorf("got translation %v, rotation %v and alpha %d, want 100, 180 and 0",
+			pic.Translate.X, pic.Rotate.Z, pic.Alpha)
+	}
+
+	material := root.MAT.Entries[0]
+	if material.Textures[0].Name != "b.tpl" {
+		t.Errorf("got texture %s, want b.tpl", material.Textures[0].Name)
+	}
+
+	// M_Back has no material colour, so animating it must not add one.
+	if material.MatColor != nil {
+		t.Errorf("got material colour %+v, want none", *material.MatColor)
+	}
+
+	want, err := testLayout().MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	root = EvaluateAnimation(layout, &Animation{FrameSize: 1, Entries: anim.Entries[1:]}, 0)
+	root.MAT.Entries[0].Textures[0].Name = "back.tpl"
+	got, err := root.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(got, want) {
+		t.Error("writing the evaluated layout changed the layout of its materials")
+	}
+}
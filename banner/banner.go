@@ -0,0 +1,313 @@
+// Package banner reads and writes opening.bnr files, the IMET headed archives that
+// hold the banner, icon and sound of a channel or disc.
+package banner
+
+import (
+	"bytes"
+	"crypto/md5"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"os"
+	"unicode/utf16"
+
+	brlyt "github.com/WiiLink24/brlytlib"
+	"github.com/WiiLink24/brlytlib/internal/binutil"
+	"github.com/WiiLink24/brlytlib/u8"
+)
+
+// Paths of the parts of a banner inside its archive.
+const (
+	BannerBin = "meta/banner.bin"
+	IconBin   = "meta/icon.bin"
+	SoundBin  = "meta/sound.bin"
+)
+
+// Language selects one of the localized titles of a banner.
+type Language int
+
+const (
+	Japanese Language = iota
+	English
+	German
+	French
+	Spanish
+	Italian
+	Dutch
+	SimplifiedChinese
+	TraditionalChinese
+	Korean
+	NumLanguages
+)
+
+var languageNames = [NumLanguages]string{
+	"Japanese",
+	"English",
+	"German",
+	"French",
+	"Spanish",
+	"Italian",
+	"Dutch",
+	"Simplified Chinese",
+	"Traditional Chinese",
+	"Korean",
+}
+
+func (l Language) String() string {
+	if l >= 0 && l < NumLanguages {
+		return languageNames[l]
+	}
+
+	return fmt.Sprintf("Language(%d)", int(l))
+}
+
+// titleLength is the number of UTF-16 code units each title has room for.
+const titleLength = 42
+
+const (
+	imetMagic = "IMET"
+	// headerSize is the size of the IMET header, which is also the size that is hashed.
+	headerSize = 0x600
+	// prefixSize is the size of the padding WAD contents place before the header.
+	prefixSize = 0x40
+)
+
+var (
+	ErrInvalidMagic = errors.New("file is not an IMET banner")
+	ErrOutOfBounds  = binutil.ErrOutOfBounds
+	ErrHashMismatch = errors.New("IMET header hash does not match its contents")
+	ErrTitleTooLong = func(language Language, title string) error {
+		return fmt.Errorf("%s title %q is longer than %d characters", language, title, titleLength)
+	}
+	ErrInvalidLanguage = func(language Language) error {
+		return fmt.Errorf("unknown language %d", int(language))
+	}
+)
+
+// Header represents the IMET header of a banner
+type Header struct {
+	_        [0x40]byte
+	Magic    [4]byte
+	HashSize uint32
+	Unknown  uint32
+	// IconSize, BannerSize and SoundSize are the sizes of the parts in the archive.
+	IconSize   uint32
+	BannerSize uint32
+	SoundSize  uint32
+	Flags      uint32
+	// Titles are null terminated UTF-16 strings, indexed by Language.
+	Titles [NumLanguages][titleLength]uint16
+	_      [0x24C]byte
+	// Hash is the MD5 of the header while the hash itself is zeroed.
+	Hash [16]byte
+}
+
+// Banner is the contents of an opening.bnr.
+type Banner struct {
+	// Prefix holds the bytes placed before the header, which WAD contents have and
+	// discs do not.
+	Prefix  []byte
+	Unknown uint32
+	Flags   uint32
+	Titles  [NumLanguages]string
+	// Archive holds banner.bin, icon.bin and sound.bin.
+	Archive *u8.Archive
+}
+
+// Parse reads an opening.bnr. The header hash is checked, while the parts are
+// only checked once they are read.
+func Parse(data []byte) (*Banner, error) {
+	// The magic follows 0x40 bytes of padding, and WAD contents place another
+	// 0x40 bytes before that, so look in both places.
+	start := 0
+	magicOffset := prefixSize + 0x40
+	if len(data) >= magicOffset+4 && string(data[magicOffset:magicOffset+4]) == imetMagic {
+		start = prefixSize
+	}
+
+	var header Header
+	err := binutil.Read(data, start, &header)
+	if err != nil {
+		return nil, err
+	}
+
+	if string(header.Magic[:]) != imetMagic {
+		return nil, ErrInvalidMagic
+	}
+
+	if header.Hash != hashHeader(data[start:start+headerSize]) {
+		return nil, ErrHashMismatch
+	}
+
+	archive, err := u8.Parse(data[start+headerSize:])
+	if err != nil {
+		return nil, err
+	}
+
+	banner := Banner{
+		Prefix:  bytes.Clone(data[:start]),
+		Unknown: header.Unknown,
+		Flags:   header.Flags,
+		Archive: archive,
+	}
+
+	for i, title := range header.Titles {
+		banner.Titles[i] = decodeTitle(title[:])
+	}
+
+	return &banner, nil
+}
+
+// Open reads the opening.bnr at name.
+func Open(name string) (*Banner, error) {
+	data, err := os.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+
+	return Parse(data)
+}
+
+// MarshalBinary encodes the banner, recomputing the part sizes and the header hash.
+func (b *Banner) MarshalBinary() ([]byte, error) {
+	header := Header{
+		HashSize: headerSize,
+		Unknown:  b.Unknown,
+		Flags:    b.Flags,
+	}
+	copy(header.Magic[:], imetMagic)
+
+	for i, title := range b.Titles {
+		encoded := utf16.Encode([]rune(title))
+		if len(encoded) > titleLength {
+			return nil, ErrTitleTooLong(Language(i), title)
+		}
+
+		copy(header.Titles[i][:], encoded)
+	}
+
+	sizes := map[string]*uint32{
+		IconBin:   &header.IconSize,
+		BannerBin: &header.BannerSize,
+		SoundBin:  &header.SoundSize,
+	}
+
+	for name, size := range sizes {
+		part, err := b.Archive.File(name)
+		if errors.Is(err, u8.ErrNotFound) {
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+
+		*size = uint32(len(part))
+	}
+
+	archive, err := b.Archive.MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+
+	file := bytes.NewBuffer(nil)
+	file.Write(b.Prefix)
+
+	err = binary.Write(file, binary.BigEndian, header)
+	if err != nil {
+		return nil, err
+	}
+
+	data := file.Bytes()
+	hash := hashHeader(data[len(b.Prefix):])
+	copy(data[len(data)-len(hash):], hash[:])
+
+	file.Write(archive)
+	return file.Bytes(), nil
+}
+
+// Title returns the title of the banner in language.
+func (b *Banner) Title(language Language) string {
+	if language < 0 || language >= NumLanguages {
+		return ""
+	}
+
+	return b.Titles[language]
+}
+
+// SetTitle sets the title of the banner in language.
+func (b *Banner) SetTitle(language Language, title string) error {
+	if language < 0 || language >= NumLanguages {
+		return ErrInvalidLanguage(language)
+	}
+
+	if len(utf16.Encode([]rune(title))) > titleLength {
+		return ErrTitleTooLong(language, title)
+	}
+
+	b.Titles[language] = title
+	return nil
+}
+
+// Part returns the archive inside one of the parts of the banner, such as BannerBin
+// or IconBin, after checking its IMD5 header and decompressing it.
+func (b *Banner) Part(name string) (*u8.Archive, error) {
+	data, err := b.Archive.File(name)
+	if err != nil {
+		return nil, err
+	}
+
+	contents, err := DecodeIMD5(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", name, err)
+	}
+
+	archive, err := u8.Parse(contents)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", name, err)
+	}
+
+	return archive, nil
+}
+
+// SetPart replaces one of the parts of the banner with archive, compressing it
+// with archive.Compression and wrapping it in an IMD5 header.
+func (b *Banner) SetPart(name string, archive *u8.Archive) error {
+	contents, err := archive.MarshalBinary()
+	if err != nil {
+		return err
+	}
+
+	part, err := EncodeIMD5(contents)
+	if err != nil {
+		return err
+	}
+
+	return b.Archive.Add(name, part)
+}
+
+// Layouts parses every BRLYT in one of the parts of the banner, keyed by path.
+func (b *Banner) Layouts(name string) (map[string]*brlyt.Root, error) {
+	archive, err := b.Part(name)
+	if err != nil {
+		return nil, err
+	}
+
+	return archive.Layouts()
+}
+
+// hashHeader returns the MD5 of an IMET header, treating its hash as zero.
+func hashHeader(header []byte) [16]byte {
+	zeroed := bytes.Clone(header[:headerSize])
+	clear(zeroed[headerSize-md5.Size:])
+	return md5.Sum(zeroed)
+}
+
+func decodeTitle(title []uint16) string {
+	for i, unit := range title {
+		if unit == 0 {
+			title = title[:i]
+			break
+		}
+	}
+
+	return string(utf16.Decode(title))
+}
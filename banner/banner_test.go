@@ -0,0 +1,103 @@
+package banner
+
+import (
+	"bytes"
+	"crypto/md5"
+	"encoding/binary"
+	"errors"
+	"testing"
+
+	"github.com/WiiLink24/brlytlib/lz"
+	"github.com/WiiLink24/brlytlib/u8"
+)
+
+func TestRoundTrip(t *testing.T) {
+	for _, prefix := range [][]byte{nil, make([]byte, prefixSize)} {
+		banner := &Banner{Prefix: prefix, Archive: &u8.Archive{}}
+		titles := map[Language]string{
+			Japanese: "バナー",
+			English:  "Banner",
+			Korean:   "배너",
+		}
+
+		for language, title := range titles {
+			err := banner.SetTitle(language, title)
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		layout := []byte("layout")
+		part := &u8.Archive{
+			Entries:     []u8.Entry{{Path: "arc/blyt/banner.brlyt", Data: layout}},
+			Compression: lz.LZ77,
+		}
+
+		err := banner.SetPart(BannerBin, part)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		data, err := banner.MarshalBinary()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		parsed, err := Parse(data)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !bytes.Equal(parsed.Prefix, prefix) {
+			t.Errorf("got a prefix of %d bytes, want %d", len(parsed.Prefix), len(prefix))
+		}
+
+		for language := range NumLanguages {
+			if got, want := parsed.Title(language), titles[language]; got != want {
+				t.Errorf("got %s title %q, want %q", language, got, want)
+			}
+		}
+
+		// The IMD5 header has to hash the compressed archive that follows it.
+		wrapped, err := parsed.Archive.File(BannerBin)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var header IMD5
+		err = binary.Read(bytes.NewReader(wrapped), binary.BigEndian, &header)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		contents := wrapped[binary.Size(header):]
+		if string(header.Magic[:]) != imd5Magic || int(header.Size) != len(contents) || header.Hash != md5.Sum(contents) {
+			t.Errorf("got IMD5 header %+v for %d bytes of contents", header, len(contents))
+		}
+
+		got, err := parsed.Part(BannerBin)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if got.Compression != lz.LZ77 {
+			t.Errorf("got compression %v, want %v", got.Compression, lz.LZ77)
+		}
+
+		file, err := got.File("arc/blyt/banner.brlyt")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !bytes.Equal(file, layout) {
+			t.Errorf("got layout %q, want %q", file, layout)
+		}
+
+		// Changing the part has to break its hash.
+		wrapped[len(wrapped)-1] ^= 0xFF
+		_, err = parsed.Part(BannerBin)
+		if !errors.Is(err, ErrIMD5Mismatch) {
+			t.Errorf("got error %v for a modified part, want %v", err, ErrIMD5Mismatch)
+		}
+	}
+}
@@ -0,0 +1,70 @@
+package banner
+
+import (
+	"bytes"
+	"crypto/md5"
+	"encoding/binary"
+	"errors"
+
+	"github.com/WiiLink24/brlytlib/internal/binutil"
+)
+
+const imd5Magic = "IMD5"
+
+var (
+	ErrInvalidIMD5Magic = errors.New("file is not IMD5 wrapped")
+	ErrIMD5Mismatch     = errors.New("IMD5 hash does not match its contents")
+)
+
+// IMD5 represents the header that wraps each part of a banner
+type IMD5 struct {
+	Magic [4]byte
+	// Size is the size of the contents following the header.
+	Size uint32
+	_    [8]byte
+	Hash [16]byte
+}
+
+// DecodeIMD5 returns the contents of data after its IMD5 header, checking them
+// against the hash in the header.
+func DecodeIMD5(data []byte) ([]byte, error) {
+	var header IMD5
+	err := binutil.Read(data, 0, &header)
+	if err != nil {
+		return nil, err
+	}
+
+	if string(header.Magic[:]) != imd5Magic {
+		return nil, ErrInvalidIMD5Magic
+	}
+
+	start := binary.Size(header)
+	if int64(header.Size) > int64(len(data)-start) {
+		return nil, ErrOutOfBounds
+	}
+
+	contents := data[start : start+int(header.Size)]
+	if md5.Sum(contents) != header.Hash {
+		return nil, ErrIMD5Mismatch
+	}
+
+	return contents, nil
+}
+
+// EncodeIMD5 wraps contents in an IMD5 header.
+func EncodeIMD5(contents []byte) ([]byte, error) {
+	header := IMD5{
+		Size: uint32(len(contents)),
+		Hash: md5.Sum(contents),
+	}
+	copy(header.Magic[:], imd5Magic)
+
+	file := bytes.NewBuffer(nil)
+	err := binary.Write(file, binary.BigEndian, header)
+	if err != nil {
+		return nil, err
+	}
+
+	file.Write(contents)
+	return file.Bytes(), nil
+}
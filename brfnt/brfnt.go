@@ -0,0 +1,373 @@
+// Package brfnt reads BRFNT fonts, giving the glyph metrics and images that text
+// panes are laid out and drawn with.
+package brfnt
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"image"
+	"os"
+
+	"github.com/WiiLink24/brlytlib/internal/binutil"
+	"github.com/WiiLink24/brlytlib/tpl"
+)
+
+const (
+	rfntMagic = "RFNT"
+	// rfnaMagic marks an archived font, whose glyph sheets are compressed.
+	rfnaMagic = "RFNA"
+)
+
+// Encoding is the character encoding the code maps of a font are in.
+type Encoding uint8
+
+const (
+	EncodingUTF8   Encoding = 0
+	EncodingUTF16  Encoding = 1
+	EncodingSJIS   Encoding = 2
+	EncodingCP1252 Encoding = 3
+)
+
+// Code mapping methods of a CMAP.
+const (
+	mappingDirect uint16 = 0
+	mappingTable  uint16 = 1
+	mappingScan   uint16 = 2
+)
+
+// noGlyph marks a code that a table mapping does not map.
+const noGlyph = 0xFFFF
+
+var (
+	ErrInvalidMagic   = errors.New("file is not a BRFNT")
+	ErrOutOfBounds    = binutil.ErrOutOfBounds
+	ErrMissingInfo    = errors.New("font has no FINF section")
+	ErrInvalidSection = func(magic string) error {
+		return fmt.Errorf("%s section is invalid", magic)
+	}
+	ErrUnknownMappingMethod = func(method uint16) error {
+		return fmt.Errorf("unknown code mapping method %d", method)
+	}
+)
+
+// Header represents the header of a BRFNT
+type Header struct {
+	Magic         [4]byte
+	BOM           uint16
+	Version       uint16
+	FileSize      uint32
+	HeaderSize    uint16
+	NumOfSections uint16
+}
+
+// SectionHeader starts every section of a BRFNT.
+type SectionHeader struct {
+	Magic [4]byte
+	Size  uint32
+}
+
+// CharWidths are the horizontal metrics of a glyph.
+type CharWidths struct {
+	// Left is the space before the glyph image.
+	Left int8
+	// GlyphWidth is the width of the glyph image.
+	GlyphWidth uint8
+	// CharWidth is how far the pen advances after the character.
+	CharWidth int8
+}
+
+// FontInfo represents the FINF section. Offsets point past the section header of the
+// section they refer to.
+type FontInfo struct {
+	FontType       uint8
+	LineFeed       int8
+	AlternateIndex uint16
+	DefaultWidth   CharWidths
+	Encoding       Encoding
+	GlyphOffset    uint32
+	WidthOffset    uint32
+	MapOffset      uint32
+	Height         uint8
+	Width          uint8
+	Ascent         uint8
+	_              uint8
+}
+
+// TextureGlyph represents the TGLP section, which describes the glyph sheets.
+type TextureGlyph struct {
+	CellWidth    uint8
+	CellHeight   uint8
+	BaselinePos  int8
+	MaxCharWidth uint8
+	SheetSize    uint32
+	NumOfSheets  uint16
+	SheetFormat  uint16
+	// SheetRow and SheetLine are the number of cells across and down a sheet.
+	SheetRow    uint16
+	SheetLine   uint16
+	SheetWidth  uint16
+	SheetHeight uint16
+	SheetOffset uint32
+}
+
+// WidthHeader represents the start of a CWDH section, which is followed by the
+// widths of each glyph from IndexBegin to IndexEnd.
+type WidthHeader struct {
+	IndexBegin uint16
+	IndexEnd   uint16
+	NextOffset uint32
+}
+
+// CodeMapHeader represents the start of a CMAP section, which is followed by the
+// mapping from character codes in CodeBegin to CodeEnd to glyph indices.
+type CodeMapHeader struct {
+	CodeBegin     uint16
+	CodeEnd       uint16
+	MappingMethod uint16
+	_             uint16
+	NextOffset    uint32
+}
+
+// Font holds the metrics and glyphs of a BRFNT.
+type Font struct {
+	Info  FontInfo
+	Glyph TextureGlyph
+	// Sheets are the decoded glyph sheets. Archived fonts compress their sheets,
+	// so they are left empty for those. I4 and I8 sheets hold the coverage of each
+	// glyph as its intensity.
+	Sheets []*image.NRGBA
+	// Widths holds the widths of glyphs by index. Glyphs without an entry use
+	// Info.DefaultWidth.
+	Widths map[uint16]CharWidths
+	// Codes maps characters to glyph indices. Characters are the codes of the font's
+	// encoding, which are UTF-16 code units for the fonts layouts use.
+	Codes map[rune]uint16
+}
+
+// Parse reads a BRFNT. Archived fonts (BRFNA) are read too, without their sheets.
+func Parse(data []byte) (*Font, error) {
+	var header Header
+	err := binutil.Read(data, 0, &header)
+	if err != nil {
+		return nil, err
+	}
+
+	magic := string(header.Magic[:])
+	if magic != rfntMagic && magic != rfnaMagic {
+		return nil, ErrInvalidMagic
+	}
+
+	font := Font{
+		Widths: map[uint16]CharWidths{},
+		Codes:  map[rune]uint16{},
+	}
+
+	hasInfo := false
+	hasGlyph := false
+	offset := int(header.HeaderSize)
+	for i := 0; i < int(header.NumOfSections); i++ {
+		var section SectionHeader
+		err = binutil.Read(data, offset, &section)
+		if err != nil {
+			return nil, err
+		}
+
+		if section.Size < 8 || int64(section.Size) > int64(len(data)-offset) {
+			return nil, ErrInvalidSection(string(section.Magic[:]))
+		}
+
+		contents := offset + 8
+		switch string(section.Magic[:]) {
+		case "FINF":
+			err = binutil.Read(data, contents, &font.Info)
+			hasInfo = true
+		case "TGLP":
+			err = binutil.Read(data, contents, &font.Glyph)
+			hasGlyph = true
+		case "CWDH":
+			err = font.parseWidths(data, contents)
+		case "CMAP":
+			err = font.parseCodeMap(data, contents)
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		offset += int(section.Size)
+	}
+
+	if !hasInfo {
+		return nil, ErrMissingInfo
+	}
+
+	if hasGlyph && magic == rfntMagic {
+		err = font.parseSheets(data)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return &font, nil
+}
+
+// Open reads the BRFNT at name.
+func Open(name string) (*Font, error) {
+	data, err := os.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+
+	return Parse(data)
+}
+
+func (f *Font) parseWidths(data []byte, offset int) error {
+	var header WidthHeader
+	err := binutil.Read(data, offset, &header)
+	if err != nil {
+		return err
+	}
+
+	if header.IndexEnd < header.IndexBegin {
+		return ErrInvalidSection("CWDH")
+	}
+
+	widths := make([]CharWidths, int(header.IndexEnd-header.IndexBegin)+1)
+	err = binutil.Read(data, offset+binary.Size(header), widths)
+	if err != nil {
+		return err
+	}
+
+	for i, width := range widths {
+		f.Widths[header.IndexBegin+uint16(i)] = width
+	}
+
+	return nil
+}
+
+func (f *Font) parseCodeMap(data []byte, offset int) error {
+	var header CodeMapHeader
+	err := binutil.Read(data, offset, &header)
+	if err != nil {
+		return err
+	}
+
+	if header.CodeEnd < header.CodeBegin {
+		return ErrInvalidSection("CMAP")
+	}
+
+	offset += binary.Size(header)
+	switch header.MappingMethod {
+	case mappingDirect:
+		// Codes map to consecutive glyphs starting from the given index.
+		var first uint16
+		err = binutil.Read(data, offset, &first)
+		if err != nil {
+			return err
+		}
+
+		for code := int(header.CodeBegin); code <= int(header.CodeEnd); code++ {
+			f.Codes[rune(code)] = first + uint16(code-int(header.CodeBegin))
+		}
+	case mappingTable:
+		indices := make([]uint16, int(header.CodeEnd-header.CodeBegin)+1)
+		err = binutil.Read(data, offset, indices)
+		if err != nil {
+			return err
+		}
+
+		for i, index := range indices {
+			if index != noGlyph {
+				f.Codes[rune(header.CodeBegin)+rune(i)] = index
+			}
+		}
+	case mappingScan:
+		var count uint16
+		err = binutil.Read(data, offset, &count)
+		if err != nil {
+			return err
+		}
+
+		pairs := make([][2]uint16, count)
+		err = binutil.Read(data, offset+2, pairs)
+		if err != nil {
+			return err
+		}
+
+		for _, pair := range pairs {
+			f.Codes[rune(pair[0])] = pair[1]
+		}
+	default:
+		return ErrUnknownMappingMethod(header.MappingMethod)
+	}
+
+	return nil
+}
+
+func (f *Font) parseSheets(data []byte) error {
+	glyph := f.Glyph
+	format := tpl.Format(glyph.SheetFormat & 0x7FFF)
+	size, err := tpl.DataSize(format, int(glyph.SheetWidth), int(glyph.SheetHeight))
+	if err != nil {
+		return err
+	}
+
+	f.Sheets = make([]*image.NRGBA, glyph.NumOfSheets)
+	for i := range f.Sheets {
+		start := int64(glyph.SheetOffset) + int64(i)*int64(glyph.SheetSize)
+		if start+int64(size) > int64(len(data)) {
+			return ErrOutOfBounds
+		}
+
+		f.Sheets[i], err = tpl.DecodePixels(data[start:start+int64(size)], format, int(glyph.SheetWidth), int(glyph.SheetHeight), nil, 0)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Index returns the glyph index of r, or the alternate glyph if the font does
+// not have it.
+func (f *Font) Index(r rune) uint16 {
+	if index, ok := f.Codes[r]; ok {
+		return index
+	}
+
+	return f.Info.AlternateIndex
+}
+
+// CharWidths returns the widths of the glyph drawn for r.
+func (f *Font) CharWidths(r rune) CharWidths {
+	if widths, ok := f.Widths[f.Index(r)]; ok {
+		return widths
+	}
+
+	return f.Info.DefaultWidth
+}
+
+// GlyphImage returns the cell of the glyph drawn for r, or nil if the sheets are
+// not available.
+func (f *Font) GlyphImage(r rune) image.Image {
+	glyph := f.Glyph
+	perSheet := int(glyph.SheetRow) * int(glyph.SheetLine)
+	if perSheet == 0 {
+		return nil
+	}
+
+	index := int(f.Index(r))
+	sheet := index / perSheet
+	if sheet >= len(f.Sheets) {
+		return nil
+	}
+
+	// Cells are separated by a pixel.
+	cell := index % perSheet
+	x := (cell % int(glyph.SheetRow)) * (int(glyph.CellWidth) + 1)
+	y := (cell / int(glyph.SheetRow)) * (int(glyph.CellHeight) + 1)
+	rect := image.Rect(x, y, x+int(glyph.CellWidth), y+int(glyph.CellHeight))
+
+	return f.Sheets[sheet].SubImage(rect)
+}
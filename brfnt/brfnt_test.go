@@ -0,0 +1,233 @@
+package brfnt
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"image"
+	"image/color"
+	"maps"
+	"testing"
+
+	"github.com/WiiLink24/brlytlib/tpl"
+)
+
+// Cells of the test font are 4x4 pixels, two across and two down its sheet.
+const (
+	testCellSize = 4
+	testSheetRow = 2
+)
+
+// section encodes a BRFNT section holding values, padded to 4 bytes.
+func section(t *testing.T, magic string, values ...any) []byte {
+	contents := bytes.NewBuffer(nil)
+	for _, v := range values {
+		err := binary.Write(contents, binary.BigEndian, v)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for contents.Len()%4 != 0 {
+		contents.WriteByte(0)
+	}
+
+	file := bytes.NewBuffer(nil)
+	file.WriteString(magic)
+	binary.Write(file, binary.BigEndian, uint32(8+contents.Len()))
+	file.Write(contents.Bytes())
+	return file.Bytes()
+}
+
+// testSheet returns a sheet whose cells are filled with 50 times their index
+// plus one, with the pixels between cells left black.
+func testSheet() *image.Gray {
+	sheet := image.NewGray(image.Rect(0, 0, 16, 12))
+	stride := testCellSize + 1
+	for y := 0; y < testSheetRow*stride; y++ {
+		for x := 0; x < testSheetRow*stride; x++ {
+			if x%stride == testCellSize || y%stride == testCellSize {
+				continue
+			}
+
+			cell := (y/stride)*testSheetRow + x/stride
+			sheet.SetGray(x, y, color.Gray{Y: uint8(50 * (cell + 1))})
+		}
+	}
+
+	return sheet
+}
+
+// testFont returns a BRFNT with a code map of every kind. The scan map lists
+// scanCount pairs, although it only holds two.
+func testFont(t *testing.T, scanCount uint16) []byte {
+	sheet := testSheet()
+	pixels, _, err := tpl.EncodePixels(sheet, tpl.I8, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	info := FontInfo{
+		FontType:       1,
+		LineFeed:       6,
+		AlternateIndex: 3,
+		DefaultWidth:   CharWidths{Left: 0, GlyphWidth: 4, CharWidth: 5},
+		Encoding:       EncodingUTF16,
+		Height:         testCellSize,
+		Width:          testCellSize,
+		Ascent:         3,
+	}
+
+	sections := [][]byte{
+		section(t, "FINF", info),
+		section(t, "CWDH", WidthHeader{IndexBegin: 0, IndexEnd: 2}, []CharWidths{
+			{Left: 0, GlyphWidth: 4, CharWidth: 4},
+			{Left: 1, GlyphWidth: 3, CharWidth: 4},
+			{Left: -1, GlyphWidth: 4, CharWidth: 3},
+		}),
+		section(t, "CMAP", CodeMapHeader{CodeBegin: 'A', CodeEnd: 'C', MappingMethod: mappingDirect}, uint16(0)),
+		section(t, "CMAP", CodeMapHeader{CodeBegin: 'a', CodeEnd: 'd', MappingMethod: mappingTable},
+			[]uint16{3, noGlyph, 1, noGlyph}),
+		section(t, "CMAP", CodeMapHeader{CodeBegin: 0x3042, CodeEnd: 0x3044, MappingMethod: mappingScan},
+			scanCount, [][2]uint16{{0x3042, 2}, {0x3044, 0}}),
+	}
+
+	// The glyph section goes first and the sheet follows every section.
+	headerSize := binary.Size(Header{})
+	sheetOffset := headerSize + 8 + binary.Size(TextureGlyph{})
+	for _, s := range sections {
+		sheetOffset += len(s)
+	}
+
+	glyph := TextureGlyph{
+		CellWidth:    testCellSize,
+		CellHeight:   testCellSize,
+		BaselinePos:  3,
+		MaxCharWidth: testCellSize,
+		SheetSize:    uint32(len(pixels)),
+		NumOfSheets:  1,
+		SheetFormat:  uint16(tpl.I8),
+		SheetRow:     testSheetRow,
+		SheetLine:    testSheetRow,
+		SheetWidth:   uint16(sheet.Bounds().Dx()),
+		SheetHeight:  uint16(sheet.Bounds().Dy()),
+		SheetOffset:  uint32(sheetOffset),
+	}
+
+	sections = append([][]byte{section(t, "TGLP", glyph)}, sections...)
+
+	file := bytes.NewBuffer(nil)
+	header := Header{
+		BOM:           0xFEFF,
+		Version:       0x0104,
+		FileSize:      uint32(sheetOffset + len(pixels)),
+		HeaderSize:    uint16(headerSize),
+		NumOfSections: uint16(len(sections)),
+	}
+	copy(header.Magic[:], rfntMagic)
+
+	binary.Write(file, binary.BigEndian, header)
+	for _, s := range sections {
+		file.Write(s)
+	}
+
+	file.Write(pixels)
+	return file.Bytes()
+}
+
+func TestParse(t *testing.T) {
+	font, err := Parse(testFont(t, 2))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if font.Info.AlternateIndex != 3 || font.Info.LineFeed != 6 {
+		t.Errorf("got font info %+v", font.Info)
+	}
+
+	// Table entries of noGlyph are left unmapped.
+	wantCodes := map[rune]uint16{
+		'A': 0, 'B': 1, 'C': 2,
+		'a': 3, 'c': 1,
+		0x3042: 2, 0x3044: 0,
+	}
+
+	if !maps.Equal(font.Codes, wantCodes) {
+		t.Errorf("got codes %v, want %v", font.Codes, wantCodes)
+	}
+
+	if len(font.Widths) != 3 {
+		t.Errorf("got %d widths, want 3", len(font.Widths))
+	}
+
+	tests := []struct {
+		char  rune
+		index uint16
+		width CharWidths
+	}{
+		{'A', 0, CharWidths{Left: 0, GlyphWidth: 4, CharWidth: 4}},
+		{'C', 2, CharWidths{Left: -1, GlyphWidth: 4, CharWidth: 3}},
+		{'c', 1, CharWidths{Left: 1, GlyphWidth: 3, CharWidth: 4}},
+		{0x3042, 2, CharWidths{Left: -1, GlyphWidth: 4, CharWidth: 3}},
+		// Glyph 3 has no widths of its own, and 'b' falls back to it.
+		{'a', 3, font.Info.DefaultWidth},
+		{'b', 3, font.Info.DefaultWidth},
+		{'Z', 3, font.Info.DefaultWidth},
+	}
+
+	for _, test := range tests {
+		if got := font.Index(test.char); got != test.index {
+			t.Errorf("got index %d for %q, want %d", got, test.char, test.index)
+		}
+
+		if got := font.CharWidths(test.char); got != test.width {
+			t.Errorf("got widths %+v for %q, want %+v", got, test.char, test.width)
+		}
+	}
+}
+
+func TestParseScanOutOfBounds(t *testing.T) {
+	_, err := Parse(testFont(t, 0xFFFF))
+	if !errors.Is(err, ErrOutOfBounds) {
+		t.Errorf("got error %v, want %v", err, ErrOutOfBounds)
+	}
+}
+
+func TestGlyphImage(t *testing.T) {
+	font, err := Parse(testFont(t, 2))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Each cell starts one pixel past the end of the one before it.
+	tests := []struct {
+		char rune
+		want image.Rectangle
+	}{
+		{'A', image.Rect(0, 0, 4, 4)},
+		{'B', image.Rect(5, 0, 9, 4)},
+		{'C', image.Rect(0, 5, 4, 9)},
+		{'a', image.Rect(5, 5, 9, 9)},
+	}
+
+	for _, test := range tests {
+		img := font.GlyphImage(test.char)
+		if img == nil {
+			t.Fatalf("got no image for %q", test.char)
+		}
+
+		if img.Bounds() != test.want {
+			t.Errorf("got cell %v for %q, want %v", img.Bounds(), test.char, test.want)
+			continue
+		}
+
+		want := uint32(50*(font.Index(test.char)+1)) * 0x101
+		for y := test.want.Min.Y; y < test.want.Max.Y; y++ {
+			for x := test.want.Min.X; x < test.want.Max.X; x++ {
+				if r, _, _, _ := img.At(x, y).RGBA(); r != want {
+					t.Errorf("got intensity %d at %d,%d for %q, want %d", r>>8, x, y, test.char, want>>8)
+				}
+			}
+		}
+	}
+}
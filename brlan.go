@@ -0,0 +1,583 @@
+package brlyt
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/xml"
+	"strings"
+)
+
+var (
+	brlanMagic     SectionTypes = [4]byte{'R', 'L', 'A', 'N'}
+	SectionTypePAI SectionTypes = [4]byte{'p', 'a', 'i', '1'}
+)
+
+// Animation tags, which select the part of a pane or material that is animated.
+const (
+	AnimationTagPaneSRT        = "RLPA"
+	AnimationTagTextureSRT     = "RLTS"
+	AnimationTagVisibility     = "RLVI"
+	AnimationTagVertexColor    = "RLVC"
+	AnimationTagMaterialColor  = "RLMC"
+	AnimationTagTexturePattern = "RLTP"
+	AnimationTagIndirectSRT    = "RLIM"
+)
+
+// Animation entry types.
+const (
+	AnimationTypePane uint8 = iota
+	AnimationTypeMaterial
+)
+
+// Curve types of an animation target.
+const (
+	CurveTypeStep    uint8 = 1
+	CurveTypeHermite uint8 = 2
+)
+
+// Targets of RLPA animations.
+const (
+	TargetTranslateX uint8 = iota
+	TargetTranslateY
+	TargetTranslateZ
+	TargetRotateX
+	TargetRotateY
+	TargetRotateZ
+	TargetScaleX
+	TargetScaleY
+	TargetWidth
+	TargetHeight
+	numPaneSRTTargets
+)
+
+// Targets of RLVC animations. The RGBA values of the four vertex colours come
+// first, in the order top left, top right, bottom left, bottom right.
+const (
+	TargetVertexColor uint8 = 0
+	TargetPaneAlpha   uint8 = 16
+)
+
+// Targets of RLMC animations. Each one is followed by its G, B and A values.
+const (
+	TargetMaterialColor uint8 = 0
+	// TargetColorRegister is the first of ForeColor, BackColor and ColorReg3.
+	TargetColorRegister uint8 = 4
+	// TargetKonstColor is the first of TevColor1 to TevColor4.
+	TargetKonstColor        uint8 = 16
+	numMaterialColorTargets       = 32
+)
+
+// Targets of RLTS and RLIM animations.
+const (
+	TargetSRTTranslateS uint8 = iota
+	TargetSRTTranslateT
+	TargetSRTRotate
+	TargetSRTScaleS
+	TargetSRTScaleT
+	numSRTTargets
+)
+
+// PAI represents the header of the pai1 section
+type PAI struct {
+	FrameSize uint16
+	Loop      uint8
+	_         uint8
+	NumOfTimg uint16
+	NumOfAnim uint16
+	// AnimOffset is relative to the beginning of the pai1 section
+	AnimOffset uint32
+}
+
+// AnimationContent is the header of an animated pane or material.
+type AnimationContent struct {
+	Name      [20]byte
+	NumOfTags uint8
+	Type      uint8
+	_         uint16
+}
+
+// AnimationInfo is the header of an animation tag.
+type AnimationInfo struct {
+	Magic        [4]byte
+	NumOfTargets uint8
+	_            [3]byte
+}
+
+// AnimationTarget is the header of a single animated value.
+type AnimationTarget struct {
+	Index     uint8
+	Target    uint8
+	CurveType uint8
+	_         uint8
+	NumOfKeys uint16
+	_         uint16
+	// KeysOffset is relative to the beginning of the target
+	KeysOffset uint32
+}
+
+type HermiteKey struct {
+	Frame float32
+	Value float32
+	Slope float32
+}
+
+type StepKey struct {
+	Frame float32
+	Value uint16
+	_     uint16
+}
+
+// ParseBRLAN parses a BRLAN animation.
+func ParseBRLAN(contents []byte) (*Animation, error) {
+	var header Header
+	err := binary.Read(bytes.NewReader(contents), binary.BigEndian, &header)
+	if err != nil {
+		return nil, &ParseError{Err: err}
+	}
+
+	if !bytes.Equal(brlanMagic[:], header.Magic[:]) {
+		return nil, &ParseError{Err: ErrInvalidBRLANMagic}
+	}
+
+	if len(contents) != int(header.FileSize) {
+		return nil, &ParseError{Err: ErrFileSizeMismatch}
+	}
+
+	anim := Animation{Version: uint16(header.BOM)}
+
+	var previous string
+	offset := int(header.HeaderLen)
+	for i := 0; i < int(header.SectionCount); i++ {
+		var sectionHeader SectionHeader
+		err = read(contents, offset, &sectionHeader)
+		if err != nil {
+			return nil, &ParseError{Offset: int64(offset), Err: err}
+		}
+
+		if sectionHeader.Size < 8 {
+			return nil, &ParseError{Section: sectionHeader.Type, Offset: int64(offset), Err: ErrInvalidSectionSize}
+		}
+
+		err = checkBounds(sectionHeader.Type.String(), offset, int(sectionHeader.Size), len(contents))
+		if err != nil {
+			return nil, &ParseError{Section: sectionHeader.Type, Offset: int64(offset), Err: err}
+		}
+
+		data := contents[offset+8 : offset+int(sectionHeader.Size)]
+
+		switch sectionHeader.Type {
+		case SectionTypePAI:
+			err = anim.ParsePAI(data)
+		default:
+			anim.Unknown = append(anim.Unknown, UnknownSection{
+				Magic: sectionHeader.Type.String(),
+				After: previous,
+				Data:  bytes.Clone(data),
+			})
+		}
+
+		if err != nil {
+			return nil, &ParseError{Section: sectionHeader.Type, Offset: int64(offset), Err: err}
+		}
+
+		previous = sectionHeader.Type.String()
+		offset += int(sectionHeader.Size)
+	}
+
+	return &anim, nil
+}
+
+func (a *Animation) ParsePAI(data []byte) error {
+	var pai PAI
+	err := read(data, 0, &pai)
+	if err != nil {
+		return err
+	}
+
+	a.FrameSize = pai.FrameSize
+	a.Loop = pai.Loop
+
+	// Texture names are used by texture pattern animations. Their offsets are
+	// relative to the start of the offset table.
+	if pai.NumOfTimg != 0 {
+		tableStart := binary.Size(pai)
+		names := make([]string, pai.NumOfTimg)
+		for i := range names {
+			var nameOffset uint32
+			err = read(data, tableStart+(i*4), &nameOffset)
+			if err != nil {
+				return err
+			}
+
+			names[i], err = readString(data, tableStart+int(nameOffset))
+			if err != nil {
+				return err
+			}
+		}
+
+		a.Textures = &TPLNames{TPLName: names}
+	}
+
+	for i := 0; i < int(pai.NumOfAnim); i++ {
+		var entryOffset uint32
+		err = read(data, int(pai.AnimOffset)-8+(i*4), &entryOffset)
+		if err != nil {
+			return err
+		}
+
+		entry, err := parseAnimationEntry(data, int(entryOffset)-8)
+		if err != nil {
+			return err
+		}
+
+		a.Entries = append(a.Entries, *entry)
+	}
+
+	return nil
+}
+
+func parseAnimationEntry(data []byte, offset int) (*AnimationEntry, error) {
+	var content AnimationContent
+	err := read(data, offset, &content)
+	if err != nil {
+		return nil, err
+	}
+
+	entry := AnimationEntry{
+		Name: strings.Replace(string(content.Name[:]), "\x00", "", -1),
+		Type: content.Type,
+	}
+
+	// Tag offsets are relative to the start of the entry.
+	for i := 0; i < int(content.NumOfTags); i++ {
+		var tagOffset uint32
+		err = read(data, offset+binary.Size(content)+(i*4), &tagOffset)
+		if err != nil {
+			return nil, err
+		}
+
+		tag, err := parseAnimationTag(data, offset+int(tagOffset))
+		if err != nil {
+			return nil, err
+		}
+
+		entry.Tags = append(entry.Tags, *tag)
+	}
+
+	return &entry, nil
+}
+
+func parseAnimationTag(data []byte, offset int) (*AnimationTag, error) {
+	var info AnimationInfo
+	err := read(data, offset, &info)
+	if err != nil {
+		return nil, err
+	}
+
+	tag := AnimationTag{Type: string(info.Magic[:])}
+
+	// Target offsets are relative to the start of the tag.
+	for i := 0; i < int(info.NumOfTargets); i++ {
+		var targetOffset uint32
+		err = read(data, offset+binary.Size(info)+(i*4), &targetOffset)
+		if err != nil {
+			return nil, err
+		}
+
+		start := offset + int(targetOffset)
+
+		var target AnimationTarget
+		err = read(data, start, &target)
+		if err != nil {
+			return nil, err
+		}
+
+		keySize := binary.Size(HermiteKey{})
+		if target.CurveType == CurveTypeStep {
+			keySize = binary.Size(StepKey{})
+		}
+
+		// Make sure the keys are there before allocating room for them.
+		keyOffset := start + int(target.KeysOffset)
+		err = checkBounds("keyframes", keyOffset, int(target.NumOfKeys)*keySize, len(data))
+		if err != nil {
+			return nil, err
+		}
+
+		xmlTarget := XMLAnimationTarget{
+			Index:     target.Index,
+			Target:    target.Target,
+			CurveType: target.CurveType,
+			Keys:      make([]Keyframe, target.NumOfKeys),
+		}
+
+		for j := range xmlTarget.Keys {
+			switch target.CurveType {
+			case CurveTypeStep:
+				var key StepKey
+				err = read(data, keyOffset, &key)
+				if err != nil {
+					return nil, err
+				}
+
+				xmlTarget.Keys[j] = Keyframe{Frame: key.Frame, Value: float32(key.Value)}
+				keyOffset += binary.Size(key)
+			case CurveTypeHermite:
+				var key HermiteKey
+				err = read(data, keyOffset, &key)
+				if err != nil {
+					return nil, err
+				}
+
+				xmlTarget.Keys[j] = Keyframe{Frame: key.Frame, Value: key.Value, Slope: key.Slope}
+				keyOffset += binary.Size(key)
+			default:
+				return nil, ErrUnknownCurveType(tag.Type, target.CurveType)
+			}
+		}
+
+		tag.Targets = append(tag.Targets, xmlTarget)
+	}
+
+	return &tag, nil
+}
+
+// readString reads a null terminated string starting at offset.
+func readString(data []byte, offset int) (string, error) {
+	err := checkBounds("string", offset, 0, len(data))
+	if err != nil {
+		return "", err
+	}
+
+	end := bytes.IndexByte(data[offset:], 0)
+	if end == -1 {
+		return "", &BoundsError{What: "string", Offset: offset, Length: len(data) - offset + 1, Size: len(data)}
+	}
+
+	return string(data[offset : offset+end]), nil
+}
+
+// WriteBRLAN converts the XML representation of an animation to a BRLAN.
+func WriteBRLAN(data []byte) ([]byte, error) {
+	var anim Animation
+	err := xml.Unmarshal(data, &anim)
+	if err != nil {
+		return nil, err
+	}
+
+	return anim.MarshalBinary()
+}
+
+// MarshalBinary encodes the animation as a BRLAN.
+func (a *Animation) MarshalBinary() ([]byte, error) {
+	writer := BRLYTWriter{Buffer: bytes.NewBuffer(nil)}
+
+	version := a.Version
+	if version == 0 {
+		version = defaultVersion
+	}
+
+	header := Header{
+		Magic:     brlanMagic,
+		BOM:       0xFEFF0000 | uint32(version),
+		HeaderLen: 16,
+	}
+
+	err := write(writer, header)
+	if err != nil {
+		return nil, err
+	}
+
+	// Unknown sections either came before pai1 or after it.
+	var leading, trailing []UnknownSection
+	for _, section := range a.Unknown {
+		if section.After == "" {
+			leading = append(leading, section)
+		} else {
+			trailing = append(trailing, section)
+		}
+	}
+
+	err = writer.writeUnknownSections(leading)
+	if err != nil {
+		return nil, err
+	}
+
+	err = writer.WritePAI(*a)
+	if err != nil {
+		return nil, err
+	}
+
+	writer.sectionCount++
+
+	err = writer.writeUnknownSections(trailing)
+	if err != nil {
+		return nil, err
+	}
+
+	binary.BigEndian.PutUint32(writer.Bytes()[8:12], uint32(writer.Len()))
+	binary.BigEndian.PutUint16(writer.Bytes()[14:16], writer.sectionCount)
+	return writer.Bytes(), nil
+}
+
+// UnmarshalBinary decodes a BRLAN, replacing the contents of a.
+func (a *Animation) UnmarshalBinary(data []byte) error {
+	anim, err := ParseBRLAN(data)
+	if err != nil {
+		return err
+	}
+
+	*a = *anim
+	return nil
+}
+
+func (b *BRLYTWriter) WritePAI(data Animation) error {
+	temp := bytes.NewBuffer(nil)
+
+	header := SectionHeader{
+		Type: SectionTypePAI,
+		Size: 0,
+	}
+
+	var textures []string
+	if data.Textures != nil {
+		textures = data.Textures.TPLName
+	}
+
+	pai := PAI{
+		FrameSize: data.FrameSize,
+		Loop:      data.Loop,
+		NumOfTimg: uint16(len(textures)),
+		NumOfAnim: uint16(len(data.Entries)),
+	}
+
+	err := write(temp, header)
+	if err != nil {
+		return err
+	}
+
+	err = write(temp, pai)
+	if err != nil {
+		return err
+	}
+
+	// Texture name offsets are relative to the start of their table, and the names follow it.
+	offset := len(textures) * 4
+	for _, name := range textures {
+		err = write(temp, uint32(offset))
+		if err != nil {
+			return err
+		}
+
+		offset += len(name) + 1
+	}
+
+	for _, name := range textures {
+		temp.WriteString(name)
+		temp.WriteByte(0)
+	}
+
+	for temp.Len()%4 != 0 {
+		temp.WriteByte(0)
+	}
+
+	// Entry offsets are relative to the start of the section.
+	binary.BigEndian.PutUint32(temp.Bytes()[16:20], uint32(temp.Len()))
+	tableStart := temp.Len()
+	temp.Write(make([]byte, len(data.Entries)*4))
+
+	for i, entry := range data.Entries {
+		binary.BigEndian.PutUint32(temp.Bytes()[tableStart+(i*4):], uint32(temp.Len()))
+
+		err = writeAnimationEntry(temp, entry)
+		if err != nil {
+			return err
+		}
+	}
+
+	binary.BigEndian.PutUint32(temp.Bytes()[4:8], uint32(temp.Len()))
+	return write(b, temp.Bytes())
+}
+
+func writeAnimationEntry(temp *bytes.Buffer, entry AnimationEntry) error {
+	var name [20]byte
+	copy(name[:], entry.Name)
+
+	content := AnimationContent{
+		Name:      name,
+		NumOfTags: uint8(len(entry.Tags)),
+		Type:      entry.Type,
+	}
+
+	entryStart := temp.Len()
+	err := write(temp, content)
+	if err != nil {
+		return err
+	}
+
+	tableStart := temp.Len()
+	temp.Write(make([]byte, len(entry.Tags)*4))
+
+	for i, tag := range entry.Tags {
+		binary.BigEndian.PutUint32(temp.Bytes()[tableStart+(i*4):], uint32(temp.Len()-entryStart))
+
+		err = writeAnimationTag(temp, tag)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func writeAnimationTag(temp *bytes.Buffer, tag AnimationTag) error {
+	if len(tag.Type) != 4 {
+		return ErrInvalidSectionMagic(tag.Type)
+	}
+
+	info := AnimationInfo{NumOfTargets: uint8(len(tag.Targets))}
+	copy(info.Magic[:], tag.Type)
+
+	tagStart := temp.Len()
+	err := write(temp, info)
+	if err != nil {
+		return err
+	}
+
+	tableStart := temp.Len()
+	temp.Write(make([]byte, len(tag.Targets)*4))
+
+	for i, xmlTarget := range tag.Targets {
+		binary.BigEndian.PutUint32(temp.Bytes()[tableStart+(i*4):], uint32(temp.Len()-tagStart))
+
+		target := AnimationTarget{
+			Index:      xmlTarget.Index,
+			Target:     xmlTarget.Target,
+			CurveType:  xmlTarget.CurveType,
+			NumOfKeys:  uint16(len(xmlTarget.Keys)),
+			KeysOffset: uint32(binary.Size(AnimationTarget{})),
+		}
+
+		err = write(temp, target)
+		if err != nil {
+			return err
+		}
+
+		for _, key := range xmlTarget.Keys {
+			switch xmlTarget.CurveType {
+			case CurveTypeStep:
+				err = write(temp, StepKey{Frame: key.Frame, Value: uint16(key.Value)})
+			case CurveTypeHermite:
+				err = write(temp, HermiteKey{Frame: key.Frame, Value: key.Value, Slope: key.Slope})
+			default:
+				err = ErrUnknownCurveType(tag.Type, xmlTarget.CurveType)
+			}
+
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
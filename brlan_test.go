@@ -0,0 +1,133 @@
+package brlyt
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/xml"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+// testAnimation returns a small animation using step and Hermite keys.
+func testAnimation() *Animation {
+	return &Animation{
+		Version:   10,
+		FrameSize: 120,
+		Loop:      1,
+		Textures:  &TPLNames{TPLName: []string{"a.tpl", "b.tpl"}},
+		Entries: []AnimationEntry{
+			{
+				Name: "P_Back",
+				Type: 0,
+				Tags: []AnimationTag{
+					{Type: AnimationTagPaneSRT, Targets: []XMLAnimationTarget{
+						{Index: 0, Target: 0, CurveType: CurveTypeHermite, Keys: []Keyframe{
+							{Frame: 0, Value: -100, Slope: 0},
+							{Frame: 60, Value: 100, Slope: 2.5},
+						}},
+						{Index: 0, Target: 5, CurveType: CurveTypeHermite, Keys: []Keyframe{
+							{Frame: 0, Value: 0},
+							{Frame: 120, Value: 360},
+						}},
+					}},
+					{Type: AnimationTagVertexColor, Targets: []XMLAnimationTarget{
+						{Index: 0, Target: 16, CurveType: CurveTypeHermite, Keys: []Keyframe{
+							{Frame: 0, Value: 255},
+							{Frame: 30, Value: 0, Slope: -1},
+						}},
+					}},
+				},
+			},
+			{
+				Name: "M_Back",
+				Type: 1,
+				Tags: []AnimationTag{
+					{Type: AnimationTagTexturePattern, Targets: []XMLAnimationTarget{
+						{Index: 0, Target: 0, CurveType: CurveTypeStep, Keys: []Keyframe{
+							{Frame: 0, Value: 0},
+							{Frame: 60, Value: 1},
+						}},
+					}},
+				},
+			},
+		},
+		Unknown: []UnknownSection{{Magic: "xyz1", After: "pai1", Data: Base64Data{1, 2, 3, 4}}},
+	}
+}
+
+func TestAnimationRoundTrip(t *testing.T) {
+	want := testAnimation()
+	data, err := want.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := ParseBRLAN(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got animation %+v, want %+v", got, want)
+	}
+
+	again, err := got.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(again, data) {
+		t.Error("writing the parsed animation changed it")
+	}
+
+	// Going through XML has to give the same file too.
+	theXML, err := xml.Marshal(got)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fromXML, err := WriteBRLAN(theXML)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(fromXML, data) {
+		t.Error("writing the animation through XML changed it")
+	}
+}
+
+func TestParseBRLANErrors(t *testing.T) {
+	data, err := testAnimation().MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The first section is larger than the rest of the file.
+	tooLarge := bytes.Clone(data)
+	binary.BigEndian.PutUint32(tooLarge[binary.Size(Header{})+4:], 0xFFFFFF00)
+
+	tests := []struct {
+		name        string
+		data        []byte
+		outOfBounds bool
+	}{
+		{"truncated header", data[:8], false},
+		{"section past the end", tooLarge, true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := ParseBRLAN(test.data)
+
+			var parseErr *ParseError
+			if !errors.As(err, &parseErr) {
+				t.Fatalf("got error %v, want a ParseError", err)
+			}
+
+			if errors.Is(err, ErrOutOfBounds) != test.outOfBounds {
+				t.Errorf("got error %v, want out of bounds to be %t", err, test.outOfBounds)
+			}
+		})
+	}
+}
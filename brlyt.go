@@ -4,7 +4,10 @@ import (
 	"bytes"
 	"encoding/binary"
 	"encoding/xml"
+	"errors"
 	"io"
+	"reflect"
+	"slices"
 )
 
 // Header represents the header of our BRLYT
@@ -16,6 +19,9 @@ type Header struct {
 	SectionCount uint16
 }
 
+// defaultVersion is the format version written when a layout does not specify one.
+const defaultVersion = 10
+
 // SectionTypes are known parts of a BRLYT.
 type SectionTypes [4]byte
 
@@ -35,17 +41,53 @@ var (
 	SectionTypeGRP SectionTypes = [4]byte{'g', 'r', 'p', '1'}
 	SectionTypeGRS SectionTypes = [4]byte{'g', 'r', 's', '1'}
 	SectionTypeGRE SectionTypes = [4]byte{'g', 'r', 'e', '1'}
-
-	sectionCount = 0
+	SectionTypeUSD SectionTypes = [4]byte{'u', 's', 'd', '1'}
 )
 
+func (s SectionTypes) String() string {
+	return string(s[:])
+}
+
+// isKnownSection reports whether sections of type s are understood by this library.
+func isKnownSection(s SectionTypes) bool {
+	switch s {
+	case SectionTypeLYT, SectionTypeTXL, SectionTypeFNL, SectionTypeMAT,
+		SectionTypePAN, SectionTypePAS, SectionTypePAE, SectionTypeBND,
+		SectionTypePIC, SectionTypeTXT, SectionTypeWND,
+		SectionTypeGRP, SectionTypeGRS, SectionTypeGRE, SectionTypeUSD:
+		return true
+	}
+
+	return false
+}
+
 type SectionHeader struct {
 	Type SectionTypes
 	Size uint32
 }
 
+// BRLYTWriter accumulates the sections of a single BRLYT. It holds all state for
+// the file being written, so separate writers can be used concurrently.
 type BRLYTWriter struct {
 	*bytes.Buffer
+
+	// sectionCount is the number of sections written so far, stored in the header once done.
+	sectionCount uint16
+
+	// fonts are the names in fnl1, which text panes are resolved against.
+	fonts []string
+
+	options WriteOptions
+}
+
+// WriteOptions control how a layout is encoded.
+type WriteOptions struct {
+	// Warn is called for problems that do not stop the layout from being written,
+	// such as a material BitFlag that disagrees with the material's entries.
+	Warn func(err error)
+	// AddMissingTextures appends textures that materials reference but txl1 lacks
+	// to txl1. Otherwise such a reference fails with ErrUnknownTexture.
+	AddMissingTextures bool
 }
 
 func ParseBRLYT(contents []byte) (*Root, error) {
@@ -54,78 +96,109 @@ func ParseBRLYT(contents []byte) (*Root, error) {
 	var header Header
 	err := binary.Read(readable, binary.BigEndian, &header)
 	if err != nil {
-		return nil, err
+		return nil, &ParseError{Err: err}
 	}
 
 	if !bytes.Equal(headerMagic[:], header.Magic[:]) {
-		return nil, ErrInvalidFileMagic
+		return nil, &ParseError{Err: ErrInvalidFileMagic}
 	}
 
 	if readable.Size() != int64(header.FileSize) {
-		return nil, ErrFileSizeMismatch
+		return nil, &ParseError{Err: ErrFileSizeMismatch}
 	}
 
 	root := Root{
 		XMLName: xml.Name{},
+		Version: uint16(header.BOM),
 		LYT:     LYTNode{},
 		FNL:     nil,
 		TXL:     nil,
 		reader:  readable,
 	}
 
+	// Unknown top level sections remember which section they followed.
+	var previous string
 	for root.count = header.SectionCount; root.count != 0; root.count-- {
-		var sectionHeader SectionHeader
-		err = binary.Read(readable, binary.BigEndian, &sectionHeader)
-		if err != nil {
-			return nil, err
-		}
-
-		// Subtract the header size
-		sectionSize := int(sectionHeader.Size) - 8
-		temp := make([]byte, sectionSize)
-		_, err = readable.Read(temp)
+		offset := root.offset()
+		sectionHeader, temp, err := root.readSection()
 		if err != nil {
-			return nil, err
+			return nil, root.parseError(sectionHeader.Type, offset, err)
 		}
 
 		switch sectionHeader.Type {
 		case SectionTypeLYT:
 			err = root.ParseLYT(temp)
-			if err != nil {
-				return nil, err
-			}
 		case SectionTypeTXL:
 			err = root.ParseTXL(temp, sectionHeader.Size)
-			if err != nil {
-				return nil, err
-			}
 		case SectionTypeFNL:
 			err = root.ParseFNL(temp, sectionHeader.Size)
-			if err != nil {
-				return nil, err
-			}
 		case SectionTypeMAT:
 			err = root.ParseMAT(temp, sectionHeader.Size)
-			if err != nil {
-				return nil, err
-			}
 		case SectionTypePAN:
 			// Root Pane is guaranteed to exist. We will sequentially read from it.
 			_, err = root.ParsePAN(temp)
-			if err != nil {
-				return nil, err
-			}
 		case SectionTypeGRP:
 			_, err = root.ParseGRP(temp)
-			if err != nil {
-				return nil, err
-			}
+		default:
+			root.Unknown = append(root.Unknown, UnknownSection{
+				Magic: sectionHeader.Type.String(),
+				After: previous,
+				Data:  temp,
+			})
+
+			continue
 		}
+
+		if err != nil {
+			return nil, root.parseError(sectionHeader.Type, offset, err)
+		}
+
+		previous = sectionHeader.Type.String()
 	}
 
 	return &root, nil
 }
 
+// ParseBRLYTFrom reads a BRLYT from r. Only the bytes covered by the file size
+// in the header are consumed, so r may be positioned inside a larger stream.
+func ParseBRLYTFrom(r io.Reader) (*Root, error) {
+	var header Header
+	err := binary.Read(r, binary.BigEndian, &header)
+	if err != nil {
+		return nil, &ParseError{Err: err}
+	}
+
+	if !bytes.Equal(headerMagic[:], header.Magic[:]) {
+		return nil, &ParseError{Err: ErrInvalidFileMagic}
+	}
+
+	if header.FileSize < uint32(binary.Size(header)) {
+		return nil, &ParseError{Err: ErrFileSizeMismatch}
+	}
+
+	contents := bytes.NewBuffer(nil)
+	err = binary.Write(contents, binary.BigEndian, header)
+	if err != nil {
+		return nil, err
+	}
+
+	// CopyN grows the buffer as data arrives rather than trusting the header size up front.
+	_, err = io.CopyN(contents, r, int64(header.FileSize)-int64(contents.Len()))
+	if errors.Is(err, io.EOF) {
+		return nil, &ParseError{Err: ErrFileSizeMismatch}
+	} else if err != nil {
+		return nil, err
+	}
+
+	return ParseBRLYT(contents.Bytes())
+}
+
+// ParseBRLYTAt reads a BRLYT of the given size from the start of r.
+func ParseBRLYTAt(r io.ReaderAt, size int64) (*Root, error) {
+	return ParseBRLYTFrom(io.NewSectionReader(r, 0, size))
+}
+
+// WriteBRLYT converts the XML representation of a layout to a BRLYT.
 func WriteBRLYT(data []byte) ([]byte, error) {
 	var root Root
 	err := xml.Unmarshal(data, &root)
@@ -133,89 +206,208 @@ func WriteBRLYT(data []byte) ([]byte, error) {
 		return nil, err
 	}
 
-	writer := BRLYTWriter{bytes.NewBuffer(nil)}
+	return root.MarshalBinary()
+}
+
+// MarshalBinary encodes the layout as a BRLYT.
+func (r *Root) MarshalBinary() ([]byte, error) {
+	return r.MarshalBinaryWithOptions(WriteOptions{})
+}
+
+// MarshalBinaryWithOptions encodes the layout as a BRLYT using the given options.
+func (r *Root) MarshalBinaryWithOptions(options WriteOptions) ([]byte, error) {
+	writer := BRLYTWriter{Buffer: bytes.NewBuffer(nil), options: options}
+	err := r.write(&writer)
+	if err != nil {
+		return nil, err
+	}
+
+	return writer.Bytes(), nil
+}
+
+// UnmarshalBinary decodes a BRLYT, replacing the contents of r.
+func (r *Root) UnmarshalBinary(data []byte) error {
+	root, err := ParseBRLYT(data)
+	if err != nil {
+		return err
+	}
+
+	*r = *root
+	return nil
+}
+
+// WriteTo encodes the layout as a BRLYT and writes it to w.
+func (r *Root) WriteTo(w io.Writer) (int64, error) {
+	return r.WriteToWithOptions(w, WriteOptions{})
+}
+
+// WriteToWithOptions encodes the layout as a BRLYT using the given options and writes it to w.
+func (r *Root) WriteToWithOptions(w io.Writer, options WriteOptions) (int64, error) {
+	writer := BRLYTWriter{Buffer: bytes.NewBuffer(nil), options: options}
+	err := r.write(&writer)
+	if err != nil {
+		return 0, err
+	}
+
+	return writer.WriteTo(w)
+}
+
+// write encodes every section of the layout into writer and fills in the file header.
+func (r *Root) write(writer *BRLYTWriter) error {
+	root := *r
+
+	// Layouts built in Go may leave the names of the root nodes unset, but parsers rely on them.
+	if root.RootPane.Name == "" {
+		root.RootPane.Name = "RootPane"
+	}
+
+	if root.RootGroup.Name == "" {
+		root.RootGroup.Name = "RootGroup"
+	}
+
+	// Materials reference textures by name, so every one of them has to be in txl1.
+	if writer.options.AddMissingTextures {
+		root.TXL = root.textureNames()
+	}
+
+	// The same goes for text panes and fonts.
+	root.FNL = root.fontNames()
+	writer.fonts = nil
+	if root.FNL != nil {
+		writer.fonts = root.FNL.FNLName
+	}
+
+	version := root.Version
+	if version == 0 {
+		version = defaultVersion
+	}
 
 	// First write the header
 	header := Header{
 		Magic:        headerMagic,
-		BOM:          0xFEFF000A,
+		BOM:          0xFEFF0000 | uint32(version),
 		FileSize:     0,
 		HeaderLen:    16,
 		SectionCount: 0,
 	}
 
-	err = binary.Write(writer, binary.BigEndian, header)
+	err := binary.Write(writer, binary.BigEndian, header)
 	if err != nil {
-		return nil, err
+		return err
 	}
 
-	sectionCount = 0
+	writer.sectionCount = 0
+
+	err = writer.writeUnknownAfter(root.Unknown, "")
+	if err != nil {
+		return err
+	}
 
 	// Write the LYT1 section
 	err = writer.WriteLYT(root)
 	if err != nil {
-		return nil, err
+		return err
+	}
+	writer.sectionCount++
+
+	err = writer.writeUnknownAfter(root.Unknown, SectionTypeLYT.String())
+	if err != nil {
+		return err
 	}
-	sectionCount++
 
 	if root.TXL != nil {
 		// Write TXL section
 		err = writer.WriteTXL(root)
 		if err != nil {
-			return nil, err
+			return err
 		}
 
-		sectionCount++
+		writer.sectionCount++
+	}
+
+	err = writer.writeUnknownAfter(root.Unknown, SectionTypeTXL.String())
+	if err != nil {
+		return err
 	}
 
 	if root.FNL != nil {
 		// Write FNL section
 		err = writer.WriteFNL(root)
 		if err != nil {
-			return nil, err
+			return err
 		}
 
-		sectionCount++
+		writer.sectionCount++
+	}
+
+	err = writer.writeUnknownAfter(root.Unknown, SectionTypeFNL.String())
+	if err != nil {
+		return err
 	}
 
 	// Write MAT section
 	err = writer.WriteMAT(root)
 	if err != nil {
-		return nil, err
+		return err
 	}
 
-	sectionCount++
+	writer.sectionCount++
+
+	err = writer.writeUnknownAfter(root.Unknown, SectionTypeMAT.String())
+	if err != nil {
+		return err
+	}
 
 	// Write RootPane then children
 	err = writer.WritePane(root.RootPane)
 	if err != nil {
-		return nil, err
+		return err
+	}
+
+	err = writer.writeAttached(root.RootPane.Attached)
+	if err != nil {
+		return err
 	}
 
 	err = writer.WriteChildren(root.RootPane.Children)
 	if err != nil {
-		return nil, err
+		return err
 	}
 
-	sectionCount++
+	writer.sectionCount++
+
+	err = writer.writeUnknownAfter(root.Unknown, SectionTypePAN.String())
+	if err != nil {
+		return err
+	}
 
 	// Same with RootGroup.
 	err = writer.WriteGRP(root.RootGroup)
 	if err != nil {
-		return nil, err
+		return err
+	}
+
+	err = writer.writeUnknownSections(root.RootGroup.Unknown)
+	if err != nil {
+		return err
 	}
 
 	err = writer.WriteGroupChildren(root.RootGroup.Children)
 	if err != nil {
-		return nil, err
+		return err
 	}
 
-	sectionCount++
+	writer.sectionCount++
+
+	err = writer.writeUnknownAfter(root.Unknown, SectionTypeGRP.String())
+	if err != nil {
+		return err
+	}
 
 	binary.BigEndian.PutUint32(writer.Bytes()[8:12], uint32(writer.Len()))
-	binary.BigEndian.PutUint16(writer.Bytes()[14:16], uint16(sectionCount))
+	binary.BigEndian.PutUint16(writer.Bytes()[14:16], writer.sectionCount)
 
-	return writer.Bytes(), nil
+	return nil
 }
 
 func (b *BRLYTWriter) WriteGroupChildren(children []Children) error {
@@ -228,7 +420,7 @@ func (b *BRLYTWriter) WriteGroupChildren(children []Children) error {
 		return err
 	}
 
-	sectionCount++
+	b.sectionCount++
 
 	for _, child := range children {
 		if child.GRP != nil {
@@ -237,13 +429,24 @@ func (b *BRLYTWriter) WriteGroupChildren(children []Children) error {
 				return err
 			}
 
-			err = b.WriteChildren(child.GRP.Children)
+			err = b.writeUnknownSections(child.GRP.Unknown)
+			if err != nil {
+				return err
+			}
+
+			err = b.WriteGroupChildren(child.GRP.Children)
+			if err != nil {
+				return err
+			}
+		}
+		if child.Unknown != nil {
+			err = b.WriteUnknown(*child.Unknown)
 			if err != nil {
 				return err
 			}
 		}
 
-		sectionCount++
+		b.sectionCount++
 	}
 
 	err = b.WriteGRE()
@@ -251,7 +454,7 @@ func (b *BRLYTWriter) WriteGroupChildren(children []Children) error {
 		return err
 	}
 
-	sectionCount++
+	b.sectionCount++
 	return nil
 }
 
@@ -266,7 +469,7 @@ func (b *BRLYTWriter) WriteChildren(children []Children) error {
 		return err
 	}
 
-	sectionCount++
+	b.sectionCount++
 
 	for _, child := range children {
 		if child.Pane != nil {
@@ -275,6 +478,11 @@ func (b *BRLYTWriter) WriteChildren(children []Children) error {
 				return err
 			}
 
+			err = b.writeAttached(child.Pane.Attached)
+			if err != nil {
+				return err
+			}
+
 			err = b.WriteChildren(child.Pane.Children)
 			if err != nil {
 				return err
@@ -286,6 +494,11 @@ func (b *BRLYTWriter) WriteChildren(children []Children) error {
 				return err
 			}
 
+			err = b.writeAttached(child.BND.Attached)
+			if err != nil {
+				return err
+			}
+
 			err = b.WriteChildren(child.BND.Children)
 			if err != nil {
 				return err
@@ -297,6 +510,11 @@ func (b *BRLYTWriter) WriteChildren(children []Children) error {
 				return err
 			}
 
+			err = b.writeAttached(child.PIC.Attached)
+			if err != nil {
+				return err
+			}
+
 			err = b.WriteChildren(child.PIC.Children)
 			if err != nil {
 				return err
@@ -308,6 +526,11 @@ func (b *BRLYTWriter) WriteChildren(children []Children) error {
 				return err
 			}
 
+			err = b.writeAttached(child.TXT.Attached)
+			if err != nil {
+				return err
+			}
+
 			err = b.WriteChildren(child.TXT.Children)
 			if err != nil {
 				return err
@@ -319,13 +542,24 @@ func (b *BRLYTWriter) WriteChildren(children []Children) error {
 				return err
 			}
 
+			err = b.writeAttached(child.WND.Attached)
+			if err != nil {
+				return err
+			}
+
 			err = b.WriteChildren(child.WND.Children)
 			if err != nil {
 				return err
 			}
 		}
+		if child.Unknown != nil {
+			err = b.WriteUnknown(*child.Unknown)
+			if err != nil {
+				return err
+			}
+		}
 
-		sectionCount++
+		b.sectionCount++
 	}
 
 	// End this pane
@@ -334,7 +568,74 @@ func (b *BRLYTWriter) WriteChildren(children []Children) error {
 		return err
 	}
 
-	sectionCount++
+	b.sectionCount++
+	return nil
+}
+
+// WriteUnknown writes a section that was preserved verbatim.
+func (b *BRLYTWriter) WriteUnknown(section UnknownSection) error {
+	if len(section.Magic) != 4 {
+		return ErrInvalidSectionMagic(section.Magic)
+	}
+
+	header := SectionHeader{Size: uint32(8 + len(section.Data))}
+	copy(header.Type[:], section.Magic)
+
+	err := write(b, header)
+	if err != nil {
+		return err
+	}
+
+	return write(b, []byte(section.Data))
+}
+
+// writeUnknownSections writes sections that were attached to a pane or group.
+func (b *BRLYTWriter) writeUnknownSections(sections []UnknownSection) error {
+	for _, section := range sections {
+		err := b.WriteUnknown(section)
+		if err != nil {
+			return err
+		}
+
+		b.sectionCount++
+	}
+
+	return nil
+}
+
+// topLevelSections lists the top level section types in the order they are written.
+var topLevelSections = []string{"", "lyt1", "txl1", "fnl1", "mat1", "pan1", "grp1"}
+
+// writeUnknownAfter writes the top level unknown sections that followed a section of
+// type after. Sections that followed something never written are kept at the end of the file.
+func (b *BRLYTWriter) writeUnknownAfter(sections []UnknownSection, after string) error {
+	var matching []UnknownSection
+	for _, section := range sections {
+		if section.After == after || (after == SectionTypeGRP.String() && !slices.Contains(topLevelSections, section.After)) {
+			matching = append(matching, section)
+		}
+	}
+
+	return b.writeUnknownSections(matching)
+}
+
+// read decodes v from data at offset, checking that all of v lies within data.
+func read(data []byte, offset int, v any) error {
+	what := reflect.TypeOf(v).Elem()
+	err := checkBounds(what.String(), offset, binary.Size(v), len(data))
+	if err != nil {
+		return err
+	}
+
+	return binary.Read(bytes.NewReader(data[offset:]), binary.BigEndian, v)
+}
+
+// checkBounds verifies that length bytes starting at offset fit within size bytes.
+func checkBounds(what string, offset int, length int, size int) error {
+	if offset < 0 || length < 0 || offset > size || length > size-offset {
+		return &BoundsError{What: what, Offset: offset, Length: length, Size: size}
+	}
+
 	return nil
 }
 
@@ -0,0 +1,368 @@
+package brlyt
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/xml"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// testLayout returns a small layout using every kind of section.
+func testLayout() *Root {
+	white := Color8{R: 255, G: 255, B: 255, A: 255}
+	uvSets := &XMLUVSets{Set: []XMLUVSet{{
+		CoordTL: STCoordinates{S: 0, T: 0},
+		CoordTR: STCoordinates{S: 1, T: 0},
+		CoordBL: STCoordinates{S: 0, T: 1},
+		CoordBR: STCoordinates{S: 1, T: 1},
+	}}}
+
+	return &Root{
+		LYT: LYTNode{Centered: 1, Width: 608, Height: 456},
+		TXL: &TPLNames{TPLName: []string{"back.tpl", "frame.tpl"}},
+		FNL: &FNLNames{FNLName: []string{"wbf1.brfnt"}},
+		MAT: MATNode{Entries: []MATEntries{
+			{
+				Name:      "M_Back",
+				BackColor: Color16{R: 255, G: 255, B: 255, A: 255},
+				Textures:  []MATTexture{{Name: "back.tpl"}},
+				SRT:       []MATSRT{{XScale: 1, YScale: 1}},
+				CoordGen:  []MATCoordGen{{Type: 1, Source: 4, MatrixSource: 30}},
+			},
+			{
+				Name:      "M_Text",
+				ForeColor: Color16{R: 255, G: 255, B: 255, A: 255},
+			},
+			{
+				Name:      "M_Frame",
+				Textures:  []MATTexture{{Name: "frame.tpl", SWrap: 1, TWrap: 1}},
+				SRT:       []MATSRT{{XScale: 1, YScale: 1}},
+				CoordGen:  []MATCoordGen{{Type: 1, Source: 4, MatrixSource: 30}},
+				BlendMode: &MATBlendMode{Type: 1, Source: 4, Destination: 5, Operator: 3},
+			},
+		}},
+		RootPane: XMLPane{
+			Name:   "RootPane",
+			Flag:   1,
+			Alpha:  255,
+			Scale:  Coord2D{X: 1, Y: 1},
+			Width:  608,
+			Height: 456,
+			Attached: []AttachedSection{
+				{Unknown: &UnknownSection{Magic: "xyz1", Data: Base64Data{5, 6, 7, 8}}},
+				{ExtUserData: &XMLExtUserData{Entries: []XMLUserDataEntry{
+					{Name: "label", Type: "string", String: "root"},
+					{Name: "ids", Type: "int", Ints: []int32{1, 2, 3}},
+				}}},
+			},
+			Children: []Children{
+				{PIC: &XMLPIC{
+					Name:             "P_Back",
+					Flag:             1,
+					Alpha:            255,
+					Scale:            Coord2D{X: 1, Y: 1},
+					Width:            608,
+					Height:           456,
+					TopLeftColor:     white,
+					TopRightColor:    white,
+					BottomLeftColor:  white,
+					BottomRightColor: white,
+					MatIndex:         0,
+					UVSets:           uvSets,
+					Attached: []AttachedSection{{ExtUserData: &XMLExtUserData{Entries: []XMLUserDataEntry{
+						{Name: "speed", Type: "float", Floats: []float32{0.5}},
+					}}}},
+				}},
+				{Pane: &XMLPane{
+					Name:      "N_Content",
+					Flag:      1,
+					Alpha:     255,
+					Translate: Coord3D{X: 0, Y: -40},
+					Scale:     Coord2D{X: 1, Y: 1},
+					Width:     400,
+					Height:    200,
+					Attached:  []AttachedSection{{Unknown: &UnknownSection{Magic: "xyz1", Data: Base64Data{1, 2, 3, 4}}}},
+					Children: []Children{
+						{TXT: &XMLTXT{
+							Name:            "T_Title",
+							Flag:            1,
+							Alpha:           255,
+							Scale:           Coord2D{X: 1, Y: 1},
+							Width:           300,
+							Height:          40,
+							StringLength:    10,
+							MaxStringLength: 20,
+							MatIndex:        1,
+							Font:            "wbf1.brfnt",
+							StringOrigin:    4,
+							XSize:           24,
+							YSize:           24,
+							TopColor:        white,
+							BottomColor:     white,
+							Text:            "Hello",
+						}},
+						{WND: &XMLWND{
+							Name:             "W_Frame",
+							Flag:             1,
+							Alpha:            255,
+							Scale:            Coord2D{X: 1, Y: 1},
+							Width:            400,
+							Height:           200,
+							Coordinate1:      8,
+							Coordinate2:      8,
+							Coordinate3:      8,
+							Coordinate4:      8,
+							TopLeftColor:     white,
+							TopRightColor:    white,
+							BottomLeftColor:  white,
+							BottomRightColor: white,
+							MatIndex:         0,
+							UVSets:           uvSets,
+							Materials:        &XMLWindowMats{Mats: []XMLWindowMat{{MatIndex: 2, Index: 0}}},
+						}},
+					},
+				}},
+				{BND: &XMLPane{
+					Name:   "B_Hit",
+					Flag:   1,
+					Alpha:  255,
+					Scale:  Coord2D{X: 1, Y: 1},
+					Width:  100,
+					Height: 50,
+				}},
+			},
+		},
+		RootGroup: XMLGRP{
+			Name: "RootGroup",
+			Children: []Children{
+				{GRP: &XMLGRP{
+					Name:    "G_All",
+					Entries: []string{"P_Back", "N_Content"},
+					Children: []Children{
+						{GRP: &XMLGRP{Name: "G_Text", Entries: []string{"T_Title"}}},
+					},
+				}},
+			},
+		},
+		Unknown: []UnknownSection{{Magic: "cnt1", After: "pan1", Data: Base64Data{0, 0, 0, 1}}},
+	}
+}
+
+func TestMarshalBinaryUnknownTexture(t *testing.T) {
+	root := testLayout()
+	root.TXL.TPLName = root.TXL.TPLName[:1]
+
+	_, err := root.MarshalBinary()
+	want := ErrUnknownTexture("M_Frame", "frame.tpl")
+	if err == nil || err.Error() != want.Error() {
+		t.Fatalf("got error %v, want %v", err, want)
+	}
+
+	data, err := root.MarshalBinaryWithOptions(WriteOptions{AddMissingTextures: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	parsed, err := ParseBRLYT(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got := strings.Join(parsed.TXL.TPLName, ","); got != "back.tpl,frame.tpl" {
+		t.Errorf("got textures %s, want back.tpl,frame.tpl", got)
+	}
+}
+
+func TestMarshalBinaryConcurrent(t *testing.T) {
+	serial, err := testLayout().MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var want Header
+	err = binary.Read(bytes.NewReader(serial), binary.BigEndian, &want)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Every layout is written at once, with a Root of its own.
+	outputs := make([][]byte, 32)
+	errs := make([]error, len(outputs))
+	var wg sync.WaitGroup
+	for i := range outputs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			outputs[i], errs[i] = testLayout().MarshalBinary()
+		}()
+	}
+
+	wg.Wait()
+
+	for i, data := range outputs {
+		if errs[i] != nil {
+			t.Fatalf("layout %d: %v", i, errs[i])
+		}
+
+		var header Header
+		err = binary.Read(bytes.NewReader(data), binary.BigEndian, &header)
+		if err != nil {
+			t.Fatalf("layout %d: %v", i, err)
+		}
+
+		if header.SectionCount != want.SectionCount || header.FileSize != want.FileSize {
+			t.Errorf("layout %d has %d sections in %d bytes, want %d sections in %d bytes",
+				i, header.SectionCount, header.FileSize, want.SectionCount, want.FileSize)
+		}
+
+		if !bytes.Equal(data, serial) {
+			t.Errorf("layout %d differs from the serial write", i)
+		}
+	}
+}
+
+func FuzzParseBRLYT(f *testing.F) {
+	full, err := testLayout().MarshalBinary()
+	if err != nil {
+		f.Fatal(err)
+	}
+
+	empty, err := (&Root{LYT: LYTNode{Width: 608, Height: 456}}).MarshalBinary()
+	if err != nil {
+		f.Fatal(err)
+	}
+
+	f.Add(full)
+	f.Add(empty)
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		_, err := ParseBRLYT(data)
+		if err == nil {
+			return
+		}
+
+		var parseErr *ParseError
+		if !errors.As(err, &parseErr) && !errors.Is(err, ErrOutOfBounds) {
+			t.Fatalf("error is neither a ParseError nor out of bounds: %v", err)
+		}
+	})
+}
+
+func TestAttachedSectionOrder(t *testing.T) {
+	data, err := testLayout().MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	root, err := ParseBRLYT(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The unknown section comes before the user data of the root pane.
+	attached := root.RootPane.Attached
+	if len(attached) != 2 || attached[0].Unknown == nil || attached[1].ExtUserData == nil {
+		t.Fatalf("got attached sections %+v, want xyz1 then usd1", attached)
+	}
+
+	again, err := root.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(again, data) {
+		t.Error("writing the parsed layout changed it")
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	data, err := testLayout().MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	written := map[SectionTypes]bool{}
+	for _, section := range splitSections(data[binary.Size(Header{}):]) {
+		written[SectionTypes(section[:4])] = true
+	}
+
+	for sectionType := range sectionStructs {
+		if !written[sectionType] {
+			t.Errorf("the test layout has no %s section", sectionType)
+		}
+	}
+
+	diffs, err := VerifyRoundTrip(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, diff := range diffs {
+		t.Error(diff)
+	}
+
+	// Going through XML has to give the same file too.
+	root, err := ParseBRLYT(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	theXML, err := xml.Marshal(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fromXML, err := WriteBRLYT(theXML)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(fromXML, data) {
+		t.Error("writing the layout through XML changed it")
+	}
+}
+
+func TestParseBRLYTFromTruncated(t *testing.T) {
+	data, err := testLayout().MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, size := range []int{0, 8, len(data) - 1} {
+		_, err = ParseBRLYTFrom(bytes.NewReader(data[:size]))
+
+		var parseErr *ParseError
+		if !errors.As(err, &parseErr) {
+			t.Errorf("got error %v for %d bytes, want a ParseError", err, size)
+		}
+	}
+}
+
+func TestWriteToWithOptions(t *testing.T) {
+	root := testLayout()
+	root.TXL.TPLName = root.TXL.TPLName[:1]
+
+	var buf bytes.Buffer
+	_, err := root.WriteTo(&buf)
+	if err == nil {
+		t.Fatal("writing a layout with a texture missing from txl1 succeeded")
+	}
+
+	buf.Reset()
+	n, err := root.WriteToWithOptions(&buf, WriteOptions{AddMissingTextures: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want, err := root.MarshalBinaryWithOptions(WriteOptions{AddMissingTextures: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if n != int64(len(want)) || !bytes.Equal(buf.Bytes(), want) {
+		t.Error("WriteToWithOptions wrote something else than MarshalBinaryWithOptions")
+	}
+}
@@ -1,30 +1,63 @@
 package main
 
 import (
+	"bytes"
 	"encoding/xml"
+	"flag"
 	brlyt "github.com/WiiLink24/brlytlib"
+	"github.com/WiiLink24/brlytlib/brfnt"
+	"github.com/WiiLink24/brlytlib/lz"
+	"github.com/WiiLink24/brlytlib/render"
+	"github.com/WiiLink24/brlytlib/tpl"
+	"github.com/WiiLink24/brlytlib/u8"
 	"log"
+	"math"
 	"os"
+	"path/filepath"
+	"strings"
+	"time"
 )
 
+const usage = `Usage: brlytlib [toXML|toBRLYT|toBRLAN] <input> <output>
+       brlytlib verify <input>
+       brlytlib lint <input.brlyt> [font.brfnt...]
+       brlytlib toSVG <input.brlyt> <output.svg>
+       brlytlib toPNG <input.tpl> <output.png>
+       brlytlib toTPL <input.png> <output.tpl> [format]
+       brlytlib extract <input.arc> <directory>
+       brlytlib pack <directory> <output.arc> [none|lz77|lz10|lz11|yaz0]
+       brlytlib render-anim <layout.brlyt> <anim.brlan> --out <output.apng|output.gif>
+                [--archive <input.arc|directory>] [--font <font.brfnt>]...
+                [--start frame] [--end frame] [--step frames] [--scale factor]`
+
+// framesPerSecond is the rate layouts are animated at.
+const framesPerSecond = 60
+
 func main() {
-	if len(os.Args) != 4 {
-		log.Println("Usage: brlytlib [toXML|toBRLYT] <input> <output>")
+	if len(os.Args) < 3 {
+		log.Println(usage)
 		os.Exit(1)
 	}
 
 	action := os.Args[1]
 	input := os.Args[2]
-	output := os.Args[3]
 
 	switch action {
 	case "toXML":
+		output := outputArg()
+
 		file, err := os.ReadFile(input)
 		if err != nil {
 			log.Fatalln(err)
 		}
 
-		root, err := brlyt.ParseBRLYT(file)
+		// Animations are converted too, telling them apart by their magic.
+		var root any
+		if bytes.HasPrefix(file, []byte("RLAN")) {
+			root, err = brlyt.ParseBRLAN(file)
+		} else {
+			root, err = brlyt.ParseBRLYT(file)
+		}
 		if err != nil {
 			log.Fatalln(err)
 		}
@@ -39,12 +72,24 @@ func main() {
 			log.Fatalln(err)
 		}
 	case "toBRLYT":
+		output := outputArg()
+
 		file, err := os.ReadFile(input)
 		if err != nil {
 			log.Fatalln(err)
 		}
 
-		data, err := brlyt.WriteBRLYT(file)
+		var root brlyt.Root
+		err = xml.Unmarshal(file, &root)
+		if err != nil {
+			log.Fatalln(err)
+		}
+
+		data, err := root.MarshalBinaryWithOptions(brlyt.WriteOptions{
+			Warn: func(err error) {
+				log.Println("warning:", err)
+			},
+		})
 		if err != nil {
 			log.Fatalln(err)
 		}
@@ -53,9 +98,328 @@ func main() {
 		if err != nil {
 			log.Fatalln(err)
 		}
+	case "toBRLAN":
+		output := outputArg()
+
+		file, err := os.ReadFile(input)
+		if err != nil {
+			log.Fatalln(err)
+		}
+
+		data, err := brlyt.WriteBRLAN(file)
+		if err != nil {
+			log.Fatalln(err)
+		}
+
+		err = os.WriteFile(output, data, 0666)
+		if err != nil {
+			log.Fatalln(err)
+		}
+	case "toSVG":
+		output := outputArg()
+
+		file, err := os.ReadFile(input)
+		if err != nil {
+			log.Fatalln(err)
+		}
+
+		root, err := brlyt.ParseBRLYT(file)
+		if err != nil {
+			log.Fatalln(err)
+		}
+
+		out := bytes.NewBuffer(nil)
+		err = render.EncodeSVG(out, root)
+		if err != nil {
+			log.Fatalln(err)
+		}
+
+		err = os.WriteFile(output, out.Bytes(), 0666)
+		if err != nil {
+			log.Fatalln(err)
+		}
+	case "toPNG":
+		output := outputArg()
+
+		file, err := os.ReadFile(input)
+		if err != nil {
+			log.Fatalln(err)
+		}
+
+		out := bytes.NewBuffer(nil)
+		err = tpl.ToPNG(out, file)
+		if err != nil {
+			log.Fatalln(err)
+		}
+
+		err = os.WriteFile(output, out.Bytes(), 0666)
+		if err != nil {
+			log.Fatalln(err)
+		}
+	case "toTPL":
+		if len(os.Args) != 4 && len(os.Args) != 5 {
+			log.Println(usage)
+			os.Exit(1)
+		}
+
+		output := os.Args[3]
+
+		// Textures keep their alpha channel unless told otherwise.
+		format := tpl.RGB5A3
+		if len(os.Args) == 5 {
+			var err error
+			format, err = tpl.ParseFormat(os.Args[4])
+			if err != nil {
+				log.Fatalln(err)
+			}
+		}
+
+		file, err := os.Open(input)
+		if err != nil {
+			log.Fatalln(err)
+		}
+
+		data, err := tpl.FromPNG(file, format)
+		file.Close()
+		if err != nil {
+			log.Fatalln(err)
+		}
+
+		err = os.WriteFile(output, data, 0666)
+		if err != nil {
+			log.Fatalln(err)
+		}
+	case "extract":
+		output := outputArg()
+
+		archive, err := u8.Open(input)
+		if err != nil {
+			log.Fatalln(err)
+		}
+
+		err = archive.Extract(output)
+		if err != nil {
+			log.Fatalln(err)
+		}
+	case "pack":
+		if len(os.Args) != 4 && len(os.Args) != 5 {
+			log.Println(usage)
+			os.Exit(1)
+		}
+
+		output := os.Args[3]
+
+		archive, err := u8.FromDirectory(input)
+		if err != nil {
+			log.Fatalln(err)
+		}
+
+		if len(os.Args) == 5 {
+			archive.Compression, err = lz.ParseMethod(os.Args[4])
+			if err != nil {
+				log.Fatalln(err)
+			}
+		}
+
+		data, err := archive.MarshalBinary()
+		if err != nil {
+			log.Fatalln(err)
+		}
+
+		err = os.WriteFile(output, data, 0666)
+		if err != nil {
+			log.Fatalln(err)
+		}
+	case "verify":
+		if len(os.Args) != 3 {
+			log.Println(usage)
+			os.Exit(1)
+		}
+
+		file, err := os.ReadFile(input)
+		if err != nil {
+			log.Fatalln(err)
+		}
+
+		diffs, err := brlyt.VerifyRoundTrip(file)
+		if err != nil {
+			log.Fatalln(err)
+		}
+
+		if len(diffs) == 0 {
+			log.Println("round-trip is byte-exact")
+			return
+		}
+
+		for _, diff := range diffs {
+			log.Println(diff)
+		}
+		os.Exit(1)
+	case "lint":
+		file, err := os.ReadFile(input)
+		if err != nil {
+			log.Fatalln(err)
+		}
+
+		root, err := brlyt.ParseBRLYT(file)
+		if err != nil {
+			log.Fatalln(err)
+		}
+
+		fonts := map[string]*brfnt.Font{}
+		openFonts(fonts, os.Args[3:])
+
+		issues := brlyt.LintText(root, fonts)
+		for _, issue := range issues {
+			log.Println(issue)
+		}
+
+		if len(issues) != 0 {
+			os.Exit(1)
+		}
+	case "render-anim":
+		renderAnimation()
 	default:
-		log.Println("Usage: brlytlib [toXML|toBRLYT] <input> <output>")
+		log.Println(usage)
+		os.Exit(1)
+	}
+
+}
+
+// outputArg returns the output path for actions that write a file.
+func outputArg() string {
+	if len(os.Args) != 4 {
+		log.Println(usage)
+		os.Exit(1)
+	}
+
+	return os.Args[3]
+}
+
+// openFonts adds the fonts at names to fonts, keyed by their file name as that is
+// how layouts refer to them.
+func openFonts(fonts map[string]*brfnt.Font, names []string) {
+	for _, name := range names {
+		font, err := brfnt.Open(name)
+		if err != nil {
+			log.Fatalln(err)
+		}
+
+		fonts[filepath.Base(name)] = font
+	}
+}
+
+// fileList is a flag that can be given several times.
+type fileList []string
+
+func (f *fileList) String() string {
+	return strings.Join(*f, ",")
+}
+
+func (f *fileList) Set(value string) error {
+	*f = append(*f, value)
+	return nil
+}
+
+// renderAnimation renders the frames of an animation playing on a layout.
+func renderAnimation() {
+	if len(os.Args) < 4 {
+		log.Println(usage)
 		os.Exit(1)
 	}
 
+	var fonts fileList
+	flags := flag.NewFlagSet("render-anim", flag.ExitOnError)
+	output := flags.String("out", "", "file to write, as a GIF if it ends in .gif and an APNG otherwise")
+	archivePath := flags.String("archive", "", "archive, or extracted directory, holding the textures and fonts of the layout")
+	flags.Var(&fonts, "font", "font to draw text with, in place of the one of the same name in the archive")
+	start := flags.Float64("start", 0, "first frame to render")
+	end := flags.Float64("end", -1, "frame to stop before, which defaults to the length of the animation")
+	step := flags.Float64("step", 1, "frames to advance between each rendered frame")
+	scale := flags.Float64("scale", 1, "factor to scale the layout by")
+	_ = flags.Parse(os.Args[4:])
+
+	if *output == "" || *step <= 0 || *scale <= 0 {
+		log.Println(usage)
+		os.Exit(1)
+	}
+
+	file, err := os.ReadFile(os.Args[2])
+	if err != nil {
+		log.Fatalln(err)
+	}
+
+	layout, err := brlyt.ParseBRLYT(file)
+	if err != nil {
+		log.Fatalln(err)
+	}
+
+	file, err = os.ReadFile(os.Args[3])
+	if err != nil {
+		log.Fatalln(err)
+	}
+
+	anim, err := brlyt.ParseBRLAN(file)
+	if err != nil {
+		log.Fatalln(err)
+	}
+
+	resources := render.Resources{Fonts: map[string]*brfnt.Font{}}
+	if *archivePath != "" {
+		// Extracted archives are read just like packed ones.
+		var archive *u8.Archive
+		info, err := os.Stat(*archivePath)
+		if err == nil && info.IsDir() {
+			archive, err = u8.FromDirectory(*archivePath)
+		} else {
+			archive, err = u8.Open(*archivePath)
+		}
+		if err != nil {
+			log.Fatalln(err)
+		}
+
+		resources, err = render.LoadResources(archive)
+		if err != nil {
+			log.Fatalln(err)
+		}
+	}
+
+	openFonts(resources.Fonts, fonts)
+
+	if *end < 0 {
+		*end = float64(max(anim.FrameSize, 1))
+	}
+
+	frames := int(math.Ceil((*end - *start) / *step))
+	if frames <= 0 {
+		log.Fatalln(render.ErrNoFrames)
+	}
+
+	// The animation is encoded in memory, so that the output is only written
+	// once every frame has rendered.
+	out := bytes.NewBuffer(nil)
+	delay := time.Duration(*step * float64(time.Second) / framesPerSecond)
+	var encoder render.FrameEncoder
+	if strings.EqualFold(filepath.Ext(*output), ".gif") {
+		encoder = render.NewGIFEncoder(out, delay)
+	} else {
+		encoder, err = render.NewAPNGEncoder(out, frames, delay)
+	}
+
+	for i := 0; i < frames && err == nil; i++ {
+		state := brlyt.EvaluateAnimation(layout, anim, float32(*start+float64(i)*(*step)))
+		err = encoder.AddFrame(render.RenderWithOptions(state, resources, render.Options{Scale: *scale}))
+	}
+
+	if err == nil {
+		err = encoder.Close()
+	}
+	if err != nil {
+		log.Fatalln(err)
+	}
+
+	err = os.WriteFile(*output, out.Bytes(), 0666)
+	if err != nil {
+		log.Fatalln(err)
+	}
 }
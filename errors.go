@@ -7,9 +7,94 @@ import (
 
 var (
 	ErrInvalidFileMagic         = errors.New("file is not a BRLYT")
+	ErrInvalidBRLANMagic        = errors.New("file is not a BRLAN")
 	ErrFileSizeMismatch         = errors.New("file size is mismatched")
 	ErrInvalidTXLHeader         = errors.New("txl1 header magic is invalid")
+	ErrOutOfBounds              = errors.New("data lies outside of its section")
+	ErrInvalidSectionSize       = errors.New("section size is smaller than its header")
+	ErrNestingTooDeep           = errors.New("panes or groups are nested too deeply")
 	ErrMisMatchedTXT1StringSize = func(stringSize int, correctSize uint16) error {
 		return fmt.Errorf("string Size (%d) does not match the size found (%d)", stringSize, correctSize)
 	}
+	ErrInvalidTextureIndex = func(index uint16, count int) error {
+		return fmt.Errorf("texture index %d is out of range for %d textures in txl1", index, count)
+	}
+	ErrInvalidSectionMagic = func(magic string) error {
+		return fmt.Errorf("section magic %q is not 4 bytes long", magic)
+	}
+	ErrInvalidUserDataType = func(valueType uint8) error {
+		return fmt.Errorf("usd1 entry has unknown value type %d", valueType)
+	}
+	ErrUnknownUserDataType = func(name string, valueType string) error {
+		return fmt.Errorf("user data entry %s has unknown type %q", name, valueType)
+	}
+	ErrInvalidFontIndex = func(index uint16, count int) error {
+		return fmt.Errorf("font index %d is out of range for %d fonts in fnl1", index, count)
+	}
+	ErrUnknownFont = func(pane string, font string) error {
+		return fmt.Errorf("text pane %s references font %s which is not in fnl1", pane, font)
+	}
+	ErrMismatchedBitFlag = func(material string, supplied uint32, computed uint32) error {
+		return fmt.Errorf("material %s has BitFlag 0x%08x but its contents require 0x%08x", material, supplied, computed)
+	}
+	ErrTooManyMaterialEntries = func(material string, entries string, count int, limit int) error {
+		return fmt.Errorf("material %s has %d %s but at most %d fit in its BitFlag", material, count, entries, limit)
+	}
+	ErrUnknownCurveType = func(tag string, curveType uint8) error {
+		return fmt.Errorf("%s animation target has unknown curve type %d", tag, curveType)
+	}
+	ErrUnknownTexture = func(material string, texture string) error {
+		return fmt.Errorf("material %s references texture %s which is not in txl1", material, texture)
+	}
 )
+
+// ParseError reports which section of a BRLYT could not be parsed and where it is.
+type ParseError struct {
+	// Section is the type of the failing section. It is zero if the section header itself
+	// could not be read, or if Offset is also zero, the file header.
+	Section SectionTypes
+	// Offset is the absolute position of the section header within the file.
+	Offset int64
+	// Path is the slash-separated list of panes or groups enclosing the section.
+	Path string
+	Err  error
+}
+
+func (e *ParseError) Error() string {
+	section := "section header"
+	if e.Section != (SectionTypes{}) {
+		section = fmt.Sprintf("%s section", e.Section)
+	} else if e.Offset == 0 {
+		section = "file header"
+	}
+
+	msg := fmt.Sprintf("%s at offset %#x", section, e.Offset)
+	if e.Path != "" {
+		msg += " in " + e.Path
+	}
+
+	return msg + ": " + e.Err.Error()
+}
+
+func (e *ParseError) Unwrap() error {
+	return e.Err
+}
+
+// BoundsError reports an offset or count read from a file that points outside
+// of the data it belongs to. It matches ErrOutOfBounds with errors.Is.
+type BoundsError struct {
+	// What names the structure being read.
+	What   string
+	Offset int
+	Length int
+	// Size is the length of the data the structure has to fit in.
+	Size int
+}
+
+func (e *BoundsError) Error() string {
+	return fmt.Sprintf("%s of %d bytes at offset %#x does not fit in %d bytes", e.What, e.Length, e.Offset, e.Size)
+}
+
+func (e *BoundsError) Is(target error) bool {
+	return target == ErrOutOfBounds
+}
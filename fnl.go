@@ -3,6 +3,7 @@ package brlyt
 import (
 	"bytes"
 	"encoding/binary"
+	"slices"
 	"strings"
 )
 
@@ -34,7 +35,7 @@ func (r *Root) ParseFNL(data []byte, sectionSize uint32) error {
 		var fnlTable FNLTable
 		offset := 4 + (i * 8)
 
-		err = binary.Read(bytes.NewReader(data[offset:]), binary.BigEndian, &fnlTable)
+		err = read(data, offset, &fnlTable)
 		if err != nil {
 			return err
 		}
@@ -49,7 +50,13 @@ func (r *Root) ParseFNL(data []byte, sectionSize uint32) error {
 
 	// Now that we have the offsets, retrieve the TPL names.
 	for i := 0; i < int(fnl.NumOfFonts); i++ {
-		fontName := string(data[fontOffsets[i]:fontOffsets[i+1]])
+		start, end := int(fontOffsets[i]), int(fontOffsets[i+1])
+		err = checkBounds("font name", start, end-start, len(data))
+		if err != nil {
+			return err
+		}
+
+		fontName := string(data[start:end])
 
 		// Strip the null terminator
 		fontName = strings.Replace(fontName, "\x00", "", -1)
@@ -62,45 +69,93 @@ func (r *Root) ParseFNL(data []byte, sectionSize uint32) error {
 }
 
 func (b *BRLYTWriter) WriteFNL(data Root) error {
-	// TODO: Write the number of fonts instead of 1. I have observed that there is only 1 fnl section so I am writing only 1.
-	temp := bytes.NewBuffer(nil)
+	sectionWriter := bytes.NewBuffer(nil)
 
 	header := SectionHeader{
 		Type: SectionTypeFNL,
-		Size: uint32(21 + len(data.FNL.FNLName[0])),
+		Size: 0,
 	}
 
-	meta := FNL{NumOfFonts: 1}
+	meta := FNL{NumOfFonts: uint16(len(data.FNL.FNLName))}
 
-	table := FNLTable{Offset: 8}
+	// Offsets are relative to the start of the offset table, and the names follow it.
+	offset := len(data.FNL.FNLName) * 8
+	offsets := make([]FNLTable, len(data.FNL.FNLName))
+	for i, name := range data.FNL.FNLName {
+		offsets[i].Offset = uint32(offset)
+		offset += len(name) + 1
+	}
 
-	err := write(temp, header)
-	if err != nil {
-		return err
+	for _, name := range data.FNL.FNLName {
+		_, err := sectionWriter.WriteString(name)
+		if err != nil {
+			return err
+		}
+
+		// Write null terminator
+		sectionWriter.WriteByte(0)
 	}
 
-	err = write(temp, meta)
+	for (b.Len()+sectionWriter.Len())%4 != 0 {
+		sectionWriter.WriteByte(0)
+	}
+
+	header.Size = uint32(12 + (8 * len(data.FNL.FNLName)) + sectionWriter.Len())
+
+	err := write(b, header)
 	if err != nil {
 		return err
 	}
 
-	err = write(temp, table)
+	err = write(b, meta)
 	if err != nil {
 		return err
 	}
 
-	_, err = temp.WriteString(data.FNL.FNLName[0])
+	err = write(b, offsets)
 	if err != nil {
 		return err
 	}
 
-	// Write null terminator
-	temp.WriteByte(0)
+	return write(b, sectionWriter.Bytes())
+}
+
+// fontNames returns the fnl1 names with every font that a text pane references
+// but is missing from the list appended to the end.
+func (r *Root) fontNames() *FNLNames {
+	var names []string
+	if r.FNL != nil {
+		names = append(names, r.FNL.FNLName...)
+	}
 
-	for (b.Len()+temp.Len())%4 != 0 {
-		temp.WriteByte(0)
+	names = appendFonts(names, r.RootPane.Children)
+	if names == nil && r.FNL == nil {
+		return nil
+	}
+
+	return &FNLNames{FNLName: names}
+}
+
+// appendFonts appends the fonts of the text panes in children that are not in names yet.
+func appendFonts(names []string, children []Children) []string {
+	for _, child := range children {
+		switch {
+		case child.Pane != nil:
+			names = appendFonts(names, child.Pane.Children)
+		case child.BND != nil:
+			names = appendFonts(names, child.BND.Children)
+		case child.PIC != nil:
+			names = appendFonts(names, child.PIC.Children)
+		case child.WND != nil:
+			names = appendFonts(names, child.WND.Children)
+		case child.TXT != nil:
+			if child.TXT.Font != "" && !slices.Contains(names, child.TXT.Font) {
+				names = append(names, child.TXT.Font)
+			}
+
+			names = appendFonts(names, child.TXT.Children)
+		}
 	}
 
-	binary.BigEndian.PutUint32(temp.Bytes()[4:8], uint32(temp.Len()))
-	return write(b, temp.Bytes())
+	return names
 }
@@ -22,7 +22,13 @@ func (r *Root) ParseGRP(data []byte) (*XMLGRP, error) {
 	for i := 0; i < int(grp.NumOfEntries); i++ {
 		offset := 20 + (i * 16)
 
-		object := strings.Replace(string(data[offset:offset+16]), "\x00", "", -1)
+		var entry [16]byte
+		err = read(data, offset, &entry)
+		if err != nil {
+			return nil, err
+		}
+
+		object := strings.Replace(string(entry[:]), "\x00", "", -1)
 		entries[i] = object
 	}
 
@@ -31,21 +37,18 @@ func (r *Root) ParseGRP(data []byte) (*XMLGRP, error) {
 		Entries: entries,
 	}
 
+	xmlData.Unknown, err = r.parseAttached()
+	if err != nil {
+		return nil, err
+	}
+
+	xmlData.Children, err = r.parseChildrenOf(name)
+	if err != nil {
+		return nil, err
+	}
+
 	if name == "RootGroup" {
 		r.RootGroup = xmlData
-		if r.HasChildren() {
-			r.RootGroup.Children, err = r.ParseChildren()
-			if err != nil {
-				return nil, err
-			}
-		}
-	} else {
-		if r.HasChildren() {
-			xmlData.Children, err = r.ParseChildren()
-			if err != nil {
-				return nil, err
-			}
-		}
 	}
 
 	return &xmlData, nil
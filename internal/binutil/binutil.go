@@ -0,0 +1,34 @@
+// Package binutil holds the helpers for reading and writing big-endian binary
+// formats shared by the packages of this module.
+package binutil
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+)
+
+// ErrOutOfBounds is returned when a value would be read from outside of its data.
+var ErrOutOfBounds = errors.New("data lies outside of the file")
+
+// Read decodes v from data at offset, failing if it does not fit.
+func Read(data []byte, offset int, v any) error {
+	size := binary.Size(v)
+	if offset < 0 || size < 0 || offset > len(data) || size > len(data)-offset {
+		return ErrOutOfBounds
+	}
+
+	return binary.Read(bytes.NewReader(data[offset:offset+size]), binary.BigEndian, v)
+}
+
+// Align rounds n up to a multiple of to.
+func Align(n int, to int) int {
+	return (n + to - 1) / to * to
+}
+
+// PadTo writes zeroes to buffer until its length is a multiple of to.
+func PadTo(buffer *bytes.Buffer, to int) {
+	for buffer.Len()%to != 0 {
+		buffer.WriteByte(0)
+	}
+}
@@ -4,80 +4,51 @@ import (
 	"encoding/binary"
 	"errors"
 	"io"
+	"strings"
 )
 
+// maxDepth limits how deeply panes and groups may nest, so crafted files cannot exhaust the stack.
+const maxDepth = 128
+
 func (r *Root) ParseChildren() ([]Children, error) {
 	var children []Children
 
 	isOver := false
 	for {
-		var sectionHeader SectionHeader
-		err := binary.Read(r.reader, binary.BigEndian, &sectionHeader)
-		if err != nil {
-			return nil, err
-		}
-
-		// Subtract the header size
-		sectionSize := int(sectionHeader.Size) - 8
-		// We could end off at a pane end, meaning it would try to read EOF
-		if sectionSize == 0 {
-			r.count--
-			break
-		}
-
-		temp := make([]byte, sectionSize)
-		_, err = r.reader.Read(temp)
+		offset := r.offset()
+		sectionHeader, temp, err := r.readSection()
 		if err != nil {
-			return nil, err
+			return nil, r.parseError(sectionHeader.Type, offset, err)
 		}
 
+		var child Children
 		switch sectionHeader.Type {
 		case SectionTypeBND:
-			bnd, err := r.ParseBND(temp)
-			if err != nil {
-				return nil, err
-			}
-
-			children = append(children, Children{BND: bnd})
+			child.BND, err = r.ParseBND(temp)
 		case SectionTypePIC:
-			pic, err := r.ParsePIC(temp)
-			if err != nil {
-				return nil, err
-			}
-
-			children = append(children, Children{PIC: pic})
+			child.PIC, err = r.ParsePIC(temp)
 		case SectionTypeTXT:
-			txt, err := r.ParseTXT(temp, sectionHeader.Size)
-			if err != nil {
-				return nil, err
-			}
-
-			children = append(children, Children{TXT: txt})
+			child.TXT, err = r.ParseTXT(temp, sectionHeader.Size)
 		case SectionTypeWND:
-			wnd, err := r.ParseWND(temp)
-			if err != nil {
-				return nil, err
-			}
-
-			children = append(children, Children{WND: wnd})
+			child.WND, err = r.ParseWND(temp)
 		case SectionTypePAN:
-			pan, err := r.ParsePAN(temp)
-			if err != nil {
-				return nil, err
-			}
-
-			children = append(children, Children{Pane: pan})
+			child.Pane, err = r.ParsePAN(temp)
 		case SectionTypeGRP:
-			grp, err := r.ParseGRP(temp)
-			if err != nil {
-				return nil, err
-			}
-
-			children = append(children, Children{GRP: grp})
+			child.GRP, err = r.ParseGRP(temp)
 		case SectionTypePAE:
 			isOver = true
 		case SectionTypeGRE:
 			isOver = true
+		default:
+			child.Unknown = &UnknownSection{Magic: sectionHeader.Type.String(), Data: temp}
+		}
+
+		if err != nil {
+			return nil, r.parseError(sectionHeader.Type, offset, err)
+		}
+
+		if child != (Children{}) {
+			children = append(children, child)
 		}
 
 		// Deincrement the amount of sections left to read.
@@ -90,26 +61,134 @@ func (r *Root) ParseChildren() ([]Children, error) {
 	return children, nil
 }
 
-func (r *Root) HasChildren() bool {
+// readSection reads the next section header and the contents that follow it.
+func (r *Root) readSection() (SectionHeader, []byte, error) {
+	var sectionHeader SectionHeader
+	err := binary.Read(r.reader, binary.BigEndian, &sectionHeader)
+	if err != nil {
+		return SectionHeader{}, nil, err
+	}
+
+	if sectionHeader.Size < 8 {
+		return sectionHeader, nil, ErrInvalidSectionSize
+	}
+
+	// Subtract the header size
+	sectionSize := int64(sectionHeader.Size) - 8
+	if sectionSize > int64(r.reader.Len()) {
+		// Don't allocate room for contents that are not there.
+		return sectionHeader, nil, io.ErrUnexpectedEOF
+	}
+
+	data := make([]byte, sectionSize)
+	_, err = io.ReadFull(r.reader, data)
+	if errors.Is(err, io.EOF) {
+		// The header promised contents that are not there.
+		err = io.ErrUnexpectedEOF
+	}
+
+	return sectionHeader, data, err
+}
+
+// HasChildren peeks at the next section and reports whether it starts a list of
+// child panes or groups. The start section is consumed if so.
+func (r *Root) HasChildren() (bool, error) {
+	offset := r.offset()
+
 	var sectionHeader SectionHeader
 	err := binary.Read(r.reader, binary.BigEndian, &sectionHeader)
 	if err != nil {
 		if errors.Is(err, io.EOF) {
-			return false
+			return false, nil
 		}
 
-		panic(err)
+		return false, r.parseError(SectionTypes{}, offset, err)
 	}
 
 	if sectionHeader.Type == SectionTypePAS || sectionHeader.Type == SectionTypeGRS {
 		// Read the pane start
 		r.count--
-		return true
+		return true, nil
 	}
 
 	_, err = r.reader.Seek(-8, io.SeekCurrent)
 	if err != nil {
-		panic(err)
+		return false, r.parseError(sectionHeader.Type, offset, err)
+	}
+
+	return false, nil
+}
+
+// parseAttached consumes the unrecognised sections directly following a pane or group.
+func (r *Root) parseAttached() ([]UnknownSection, error) {
+	var sections []UnknownSection
+	for {
+		offset := r.offset()
+
+		var sectionHeader SectionHeader
+		err := binary.Read(r.reader, binary.BigEndian, &sectionHeader)
+		if errors.Is(err, io.EOF) {
+			return sections, nil
+		} else if err != nil {
+			return nil, r.parseError(SectionTypes{}, offset, err)
+		}
+
+		_, err = r.reader.Seek(offset, io.SeekStart)
+		if err != nil {
+			return nil, r.parseError(sectionHeader.Type, offset, err)
+		}
+
+		// User data is kept with whatever it follows, even though it is understood.
+		if sectionHeader.Type != SectionTypeUSD && isKnownSection(sectionHeader.Type) {
+			return sections, nil
+		}
+
+		sectionHeader, data, err := r.readSection()
+		if err != nil {
+			return nil, r.parseError(sectionHeader.Type, offset, err)
+		}
+
+		sections = append(sections, UnknownSection{Magic: sectionHeader.Type.String(), Data: data})
+		r.count--
+	}
+}
+
+// parseChildrenOf parses the children following the pane or group called name, if it has any.
+func (r *Root) parseChildrenOf(name string) ([]Children, error) {
+	hasChildren, err := r.HasChildren()
+	if err != nil || !hasChildren {
+		return nil, err
+	}
+
+	if len(r.path) >= maxDepth {
+		return nil, ErrNestingTooDeep
+	}
+
+	r.path = append(r.path, name)
+	defer func() {
+		r.path = r.path[:len(r.path)-1]
+	}()
+
+	return r.ParseChildren()
+}
+
+// offset returns the absolute position of the reader within the file.
+func (r *Root) offset() int64 {
+	return r.reader.Size() - int64(r.reader.Len())
+}
+
+// parseError attaches the location of a section to err. Errors that already carry
+// the location of a nested section are returned unchanged.
+func (r *Root) parseError(section SectionTypes, offset int64, err error) error {
+	var parseErr *ParseError
+	if errors.As(err, &parseErr) {
+		return err
+	}
+
+	return &ParseError{
+		Section: section,
+		Offset:  offset,
+		Path:    strings.Join(r.path, "/"),
+		Err:     err,
 	}
-	return false
 }
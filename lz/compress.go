@@ -0,0 +1,192 @@
+package lz
+
+import (
+	"encoding/binary"
+)
+
+const (
+	// windowSize is how far back both formats can refer.
+	windowSize = 0x1000
+	minMatch   = 3
+	// maxChain limits how many earlier positions are tried for each match.
+	maxChain = 128
+	hashBits = 15
+)
+
+// matcher finds the longest earlier occurrence of the data at a position.
+type matcher struct {
+	data      []byte
+	maxLength int
+	head      []int32
+	prev      []int32
+}
+
+func newMatcher(data []byte, maxLength int) *matcher {
+	m := &matcher{
+		data:      data,
+		maxLength: maxLength,
+		head:      make([]int32, 1<<hashBits),
+		prev:      make([]int32, len(data)),
+	}
+
+	for i := range m.head {
+		m.head[i] = -1
+	}
+
+	return m
+}
+
+func (m *matcher) hash(pos int) uint32 {
+	v := uint32(m.data[pos])<<16 | uint32(m.data[pos+1])<<8 | uint32(m.data[pos+2])
+	return (v * 2654435761) >> (32 - hashBits)
+}
+
+// insert records the position so that later matches can refer to it.
+func (m *matcher) insert(pos int) {
+	if pos+minMatch > len(m.data) {
+		return
+	}
+
+	h := m.hash(pos)
+	m.prev[pos] = m.head[h]
+	m.head[h] = int32(pos)
+}
+
+// find returns the distance and length of the longest match for pos, or a length
+// of zero if there is none.
+func (m *matcher) find(pos int) (int, int) {
+	if pos+minMatch > len(m.data) {
+		return 0, 0
+	}
+
+	limit := min(m.maxLength, len(m.data)-pos)
+	bestDistance, bestLength := 0, 0
+
+	candidate := int(m.head[m.hash(pos)])
+	for chain := 0; candidate >= 0 && pos-candidate <= windowSize && chain < maxChain; chain++ {
+		length := 0
+		for length < limit && m.data[candidate+length] == m.data[pos+length] {
+			length++
+		}
+
+		if length > bestLength {
+			bestDistance, bestLength = pos-candidate, length
+			if length == limit {
+				break
+			}
+		}
+
+		candidate = int(m.prev[candidate])
+	}
+
+	if bestLength < minMatch {
+		return 0, 0
+	}
+
+	return bestDistance, bestLength
+}
+
+// advance inserts every position from pos up to end.
+func (m *matcher) advance(pos int, end int) {
+	for ; pos < end; pos++ {
+		m.insert(pos)
+	}
+}
+
+// compressLZ77 compresses data as LZ77 of the given type, 0x10 or 0x11.
+func compressLZ77(data []byte, kind byte) []byte {
+	maxLength := 0x12
+	if kind == 0x11 {
+		maxLength = 0x10110
+	}
+
+	// A size of zero in an LZ11 header means the real size follows, even when
+	// that is zero too.
+	var out []byte
+	if len(data) <= 0xFFFFFF && (kind == 0x10 || len(data) != 0) {
+		out = binary.LittleEndian.AppendUint32(out, uint32(kind)|uint32(len(data))<<8)
+	} else {
+		// Only LZ11 can hold the size, after a header with a size of zero.
+		out = append(out, kind, 0, 0, 0)
+		out = binary.LittleEndian.AppendUint32(out, uint32(len(data)))
+	}
+
+	m := newMatcher(data, maxLength)
+	pos := 0
+	for pos < len(data) {
+		flagPos := len(out)
+		out = append(out, 0)
+
+		for bit := 7; bit >= 0 && pos < len(data); bit-- {
+			distance, length := m.find(pos)
+			if length == 0 {
+				out = append(out, data[pos])
+				m.insert(pos)
+				pos++
+				continue
+			}
+
+			out[flagPos] |= 1 << bit
+			d := distance - 1
+			switch {
+			case kind == 0x10:
+				out = append(out, byte((length-3)<<4|d>>8), byte(d))
+			case length <= 0x10:
+				out = append(out, byte((length-1)<<4|d>>8), byte(d))
+			case length <= 0x110:
+				l := length - 0x11
+				out = append(out, byte(l>>4), byte(l<<4|d>>8), byte(d))
+			default:
+				l := length - 0x111
+				out = append(out, byte(0x10|l>>12), byte(l>>4), byte(l<<4|d>>8), byte(d))
+			}
+
+			m.advance(pos, pos+length)
+			pos += length
+		}
+	}
+
+	// Compressed files are padded to a multiple of 4 bytes.
+	for len(out)%4 != 0 {
+		out = append(out, 0)
+	}
+
+	return out
+}
+
+// compressYaz0 compresses data as Yaz0.
+func compressYaz0(data []byte) []byte {
+	out := append([]byte(nil), yaz0Magic...)
+	out = binary.BigEndian.AppendUint32(out, uint32(len(data)))
+	out = append(out, make([]byte, 8)...)
+
+	m := newMatcher(data, 0xFF+0x12)
+	pos := 0
+	for pos < len(data) {
+		flagPos := len(out)
+		out = append(out, 0)
+
+		for bit := 7; bit >= 0 && pos < len(data); bit-- {
+			distance, length := m.find(pos)
+			if length == 0 {
+				out[flagPos] |= 1 << bit
+				out = append(out, data[pos])
+				m.insert(pos)
+				pos++
+				continue
+			}
+
+			d := distance - 1
+			if length < 0x12 {
+				out = append(out, byte((length-2)<<4|d>>8), byte(d))
+			} else {
+				out = append(out, byte(d>>8), byte(d), byte(length-0x12))
+			}
+
+			m.advance(pos, pos+length)
+			pos += length
+		}
+	}
+
+	return out
+}
@@ -0,0 +1,312 @@
+// Package lz compresses and decompresses the LZ77 and Yaz0 formats that banner
+// and channel archives are stored in.
+package lz
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+// Method is a compression format.
+type Method int
+
+const (
+	// None leaves data uncompressed.
+	None Method = iota
+	// LZ77 is LZ77 type 0x10 data preceded by an "LZ77" magic, as used by banners.
+	LZ77
+	// LZ10 is LZ77 type 0x10 data without a magic.
+	LZ10
+	// LZ11 is LZ77 type 0x11 data without a magic, which allows longer matches.
+	LZ11
+	// Yaz0 is the format used by many first party titles.
+	Yaz0
+)
+
+var methodNames = map[Method]string{
+	None: "none",
+	LZ77: "lz77",
+	LZ10: "lz10",
+	LZ11: "lz11",
+	Yaz0: "yaz0",
+}
+
+func (m Method) String() string {
+	if name, ok := methodNames[m]; ok {
+		return name
+	}
+
+	return fmt.Sprintf("Method(%d)", int(m))
+}
+
+// ParseMethod returns the method with the given name, such as lz77 or yaz0.
+func ParseMethod(name string) (Method, error) {
+	for method, methodName := range methodNames {
+		if methodName == name {
+			return method, nil
+		}
+	}
+
+	return 0, ErrUnknownMethod(name)
+}
+
+var (
+	lz77Magic = []byte("LZ77")
+	yaz0Magic = []byte("Yaz0")
+)
+
+var (
+	ErrTruncated     = errors.New("compressed data ends early")
+	ErrInvalidOffset = errors.New("compressed data refers back past its start")
+	ErrUnknownMethod = func(name string) error {
+		return fmt.Errorf("unknown compression method %q", name)
+	}
+	ErrUnsupportedMethod = func(method Method) error {
+		return fmt.Errorf("cannot compress with %s", method)
+	}
+	ErrTooLarge = func(method Method, size int) error {
+		return fmt.Errorf("%d bytes are too many to compress with %s", size, method)
+	}
+)
+
+// Detect returns the compression method used by data. Raw LZ77 data has no magic,
+// so it is recognised by its type byte; callers that know what the decompressed
+// data should start with ought to check it.
+func Detect(data []byte) Method {
+	switch {
+	case bytes.HasPrefix(data, yaz0Magic) && len(data) >= 16:
+		return Yaz0
+	case bytes.HasPrefix(data, lz77Magic) && len(data) >= 8 && data[4] == 0x10:
+		return LZ77
+	case len(data) >= 4 && data[0] == 0x10:
+		return LZ10
+	case len(data) >= 4 && data[0] == 0x11:
+		return LZ11
+	}
+
+	return None
+}
+
+// Decompress detects the compression method of data and decompresses it.
+// Data that is not compressed is returned as is, along with None.
+func Decompress(data []byte) ([]byte, Method, error) {
+	method := Detect(data)
+
+	var out []byte
+	var err error
+	switch method {
+	case None:
+		return data, None, nil
+	case LZ77:
+		out, err = decompressLZ77(data[4:])
+	case LZ10, LZ11:
+		out, err = decompressLZ77(data)
+	case Yaz0:
+		out, err = decompressYaz0(data)
+	}
+
+	if err != nil {
+		return nil, method, err
+	}
+
+	return out, method, nil
+}
+
+// Compress compresses data with method. None returns data unchanged.
+func Compress(data []byte, method Method) ([]byte, error) {
+	switch method {
+	case None:
+		return data, nil
+	case LZ77, LZ10:
+		// Type 0x10 has no room for larger sizes.
+		if len(data) > 0xFFFFFF {
+			return nil, ErrTooLarge(method, len(data))
+		}
+
+		if method == LZ10 {
+			return compressLZ77(data, 0x10), nil
+		}
+
+		return append(bytes.Clone(lz77Magic), compressLZ77(data, 0x10)...), nil
+	case LZ11:
+		return compressLZ77(data, 0x11), nil
+	case Yaz0:
+		return compressYaz0(data), nil
+	}
+
+	return nil, ErrUnsupportedMethod(method)
+}
+
+// output accumulates decompressed data, never growing past size.
+type output struct {
+	data []byte
+	size int
+}
+
+func newOutput(size int, compressed int) *output {
+	// Don't trust the size in the header for the initial allocation.
+	return &output{data: make([]byte, 0, min(size, compressed*8)), size: size}
+}
+
+func (o *output) done() bool {
+	return len(o.data) >= o.size
+}
+
+func (o *output) literal(b byte) {
+	o.data = append(o.data, b)
+}
+
+func (o *output) copy(distance int, length int) error {
+	start := len(o.data) - distance
+	if start < 0 {
+		return ErrInvalidOffset
+	}
+
+	// Copies may overlap the bytes they produce, so go one byte at a time.
+	for i := 0; i < length && !o.done(); i++ {
+		o.data = append(o.data, o.data[start+i])
+	}
+
+	return nil
+}
+
+func decompressLZ77(data []byte) ([]byte, error) {
+	if len(data) < 4 {
+		return nil, ErrTruncated
+	}
+
+	kind := data[0]
+	size := int(data[1]) | int(data[2])<<8 | int(data[3])<<16
+	pos := 4
+
+	// LZ11 stores sizes that do not fit in 24 bits after the header.
+	if size == 0 && kind == 0x11 {
+		if len(data) < 8 {
+			return nil, ErrTruncated
+		}
+
+		size = int(binary.LittleEndian.Uint32(data[4:8]))
+		pos = 8
+	}
+
+	out := newOutput(size, len(data))
+	for !out.done() {
+		if pos >= len(data) {
+			return nil, ErrTruncated
+		}
+
+		flags := data[pos]
+		pos++
+
+		for bit := 7; bit >= 0 && !out.done(); bit-- {
+			if flags&(1<<bit) == 0 {
+				if pos >= len(data) {
+					return nil, ErrTruncated
+				}
+
+				out.literal(data[pos])
+				pos++
+				continue
+			}
+
+			var length, distance int
+			if kind == 0x10 {
+				if pos+2 > len(data) {
+					return nil, ErrTruncated
+				}
+
+				length = int(data[pos]>>4) + 3
+				distance = (int(data[pos]&0xF)<<8 | int(data[pos+1])) + 1
+				pos += 2
+			} else {
+				if pos+2 > len(data) {
+					return nil, ErrTruncated
+				}
+
+				switch data[pos] >> 4 {
+				case 0:
+					if pos+3 > len(data) {
+						return nil, ErrTruncated
+					}
+
+					length = (int(data[pos]&0xF)<<4 | int(data[pos+1]>>4)) + 0x11
+					distance = (int(data[pos+1]&0xF)<<8 | int(data[pos+2])) + 1
+					pos += 3
+				case 1:
+					if pos+4 > len(data) {
+						return nil, ErrTruncated
+					}
+
+					length = (int(data[pos]&0xF)<<12 | int(data[pos+1])<<4 | int(data[pos+2]>>4)) + 0x111
+					distance = (int(data[pos+2]&0xF)<<8 | int(data[pos+3])) + 1
+					pos += 4
+				default:
+					length = int(data[pos]>>4) + 1
+					distance = (int(data[pos]&0xF)<<8 | int(data[pos+1])) + 1
+					pos += 2
+				}
+			}
+
+			err := out.copy(distance, length)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return out.data, nil
+}
+
+func decompressYaz0(data []byte) ([]byte, error) {
+	size := int(binary.BigEndian.Uint32(data[4:8]))
+	pos := 16
+
+	out := newOutput(size, len(data))
+	for !out.done() {
+		if pos >= len(data) {
+			return nil, ErrTruncated
+		}
+
+		flags := data[pos]
+		pos++
+
+		// Unlike LZ77, a set bit means a literal byte.
+		for bit := 7; bit >= 0 && !out.done(); bit-- {
+			if flags&(1<<bit) != 0 {
+				if pos >= len(data) {
+					return nil, ErrTruncated
+				}
+
+				out.literal(data[pos])
+				pos++
+				continue
+			}
+
+			if pos+2 > len(data) {
+				return nil, ErrTruncated
+			}
+
+			distance := (int(data[pos]&0xF)<<8 | int(data[pos+1])) + 1
+			length := int(data[pos]>>4) + 2
+			pos += 2
+
+			if length == 2 {
+				if pos >= len(data) {
+					return nil, ErrTruncated
+				}
+
+				length = int(data[pos]) + 0x12
+				pos++
+			}
+
+			err := out.copy(distance, length)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return out.data, nil
+}
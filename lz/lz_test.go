@@ -0,0 +1,46 @@
+package lz
+
+import (
+	"bytes"
+	"math/rand"
+	"testing"
+)
+
+func TestCompressRoundTrip(t *testing.T) {
+	random := make([]byte, 10000)
+	rand.New(rand.NewSource(1)).Read(random)
+
+	inputs := map[string][]byte{
+		"empty":          {},
+		"single byte":    {0x42},
+		"incompressible": random,
+		// Runs longer than the longest match of every format, which is 0x10110 bytes for LZ11.
+		"long run":       bytes.Repeat([]byte{0xAA}, 0x30000),
+		"repeated block": bytes.Repeat([]byte("layout"), 0x8000),
+		"mixed":          append(append(bytes.Clone(random[:500]), bytes.Repeat([]byte{0}, 5000)...), random[:500]...),
+	}
+
+	for _, method := range []Method{LZ77, LZ10, LZ11, Yaz0} {
+		for name, input := range inputs {
+			t.Run(method.String()+"/"+name, func(t *testing.T) {
+				compressed, err := Compress(input, method)
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				output, detected, err := Decompress(compressed)
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				if detected != method {
+					t.Errorf("detected %s, want %s", detected, method)
+				}
+
+				if !bytes.Equal(output, input) {
+					t.Errorf("got %d bytes back, want the original %d bytes", len(output), len(input))
+				}
+			})
+		}
+	}
+}
@@ -3,6 +3,7 @@ package brlyt
 import (
 	"bytes"
 	"encoding/binary"
+	"slices"
 	"strings"
 )
 
@@ -103,7 +104,7 @@ type MatAlphaCompare struct {
 
 func (r *Root) ParseMAT(data []byte, sectionSize uint32) error {
 	var mat MAT
-	var materialOffsets []uint32
+	var materialOffsets []int
 	var matEntries []MATEntries
 
 	err := binary.Read(bytes.NewReader(data), binary.BigEndian, &mat)
@@ -115,24 +116,19 @@ func (r *Root) ParseMAT(data []byte, sectionSize uint32) error {
 		var matOffset MATOffset
 		offset := 4 + (i * 4)
 
-		err = binary.Read(bytes.NewReader(data[offset:]), binary.BigEndian, &matOffset)
+		err = read(data, offset, &matOffset)
 		if err != nil {
 			return err
 		}
 
-		materialOffsets = append(materialOffsets, matOffset.Offset-8)
-
-		// If we have reached the last index, append the section size to the slice.
-		if i == int(mat.NumOfMats)-1 {
-			materialOffsets = append(materialOffsets, sectionSize-8)
-		}
+		materialOffsets = append(materialOffsets, int(matOffset.Offset)-8)
 	}
 
 	// Now that we have the offsets, parse the mat section.
 	for i := 0; i < int(mat.NumOfMats); i++ {
 		var matMaterials MATMaterials
 
-		err = binary.Read(bytes.NewReader(data[materialOffsets[i]:materialOffsets[i+1]]), binary.BigEndian, &matMaterials)
+		err = read(data, materialOffsets[i], &matMaterials)
 		if err != nil {
 			return err
 		}
@@ -144,7 +140,7 @@ func (r *Root) ParseMAT(data []byte, sectionSize uint32) error {
 		for i := 0; i < BitExtract(matMaterials.BitFlag, 28, 31); i++ {
 			var texEntry MATTextureEntry
 
-			err = binary.Read(bytes.NewReader(data[offset:]), binary.BigEndian, &texEntry)
+			err = read(data, offset, &texEntry)
 			if err != nil {
 				return err
 			}
@@ -155,7 +151,11 @@ func (r *Root) ParseMAT(data []byte, sectionSize uint32) error {
 				TWrap: texEntry.TWrap,
 			}
 
-			if r.TXL.TPLName != nil {
+			if r.TXL != nil {
+				if int(texEntry.TexIndex) >= len(r.TXL.TPLName) {
+					return ErrInvalidTextureIndex(texEntry.TexIndex, len(r.TXL.TPLName))
+				}
+
 				xmlTexture.Name = r.TXL.TPLName[texEntry.TexIndex]
 			}
 
@@ -167,7 +167,7 @@ func (r *Root) ParseMAT(data []byte, sectionSize uint32) error {
 		for i := 0; i < BitExtract(matMaterials.BitFlag, 24, 27); i++ {
 			var texSRTEntry MATTextureSRTEntry
 
-			err = binary.Read(bytes.NewReader(data[offset:]), binary.BigEndian, &texSRTEntry)
+			err = read(data, offset, &texSRTEntry)
 			if err != nil {
 				return err
 			}
@@ -188,7 +188,7 @@ func (r *Root) ParseMAT(data []byte, sectionSize uint32) error {
 		for i := 0; i < BitExtract(matMaterials.BitFlag, 20, 23); i++ {
 			var texCoorGenEntry MATTexCoordGenEntry
 
-			err = binary.Read(bytes.NewReader(data[offset:]), binary.BigEndian, &texCoorGenEntry)
+			err = read(data, offset, &texCoorGenEntry)
 			if err != nil {
 				return err
 			}
@@ -207,14 +207,14 @@ func (r *Root) ParseMAT(data []byte, sectionSize uint32) error {
 		if BitExtract(matMaterials.BitFlag, 6, 100) == 1 {
 			var chanControl MATChanControl
 
-			err = binary.Read(bytes.NewReader(data[offset:]), binary.BigEndian, &chanControl)
+			err = read(data, offset, &chanControl)
 			if err != nil {
 				return err
 			}
 
 			chanControlXML = &ChanControlXML{
 				ColorMaterialSource: chanControl.ColorMaterialSource,
-				AlphaMaterialSource: chanControl.ColorMaterialSource,
+				AlphaMaterialSource: chanControl.AlphaMaterialSource,
 			}
 
 			offset += 4
@@ -224,7 +224,7 @@ func (r *Root) ParseMAT(data []byte, sectionSize uint32) error {
 		if BitExtract(matMaterials.BitFlag, 4, 100) == 1 {
 			var matColor MATColor
 
-			err = binary.Read(bytes.NewReader(data[offset:]), binary.BigEndian, &matColor)
+			err = read(data, offset, &matColor)
 			if err != nil {
 				return err
 			}
@@ -243,7 +243,7 @@ func (r *Root) ParseMAT(data []byte, sectionSize uint32) error {
 		if BitExtract(matMaterials.BitFlag, 19, 100) == 1 {
 			var tevSwapModeTable TevSwapModeTable
 
-			err = binary.Read(bytes.NewReader(data[offset:]), binary.BigEndian, &tevSwapModeTable)
+			err = read(data, offset, &tevSwapModeTable)
 			if err != nil {
 				return err
 			}
@@ -274,7 +274,7 @@ func (r *Root) ParseMAT(data []byte, sectionSize uint32) error {
 		for i := 0; i < BitExtract(matMaterials.BitFlag, 17, 18); i++ {
 			var texSRTEntry MATTextureSRTEntry
 
-			err = binary.Read(bytes.NewReader(data[offset:]), binary.BigEndian, &texSRTEntry)
+			err = read(data, offset, &texSRTEntry)
 			if err != nil {
 				return err
 			}
@@ -295,7 +295,7 @@ func (r *Root) ParseMAT(data []byte, sectionSize uint32) error {
 		for i := 0; i < BitExtract(matMaterials.BitFlag, 14, 16); i++ {
 			var texOrderEntry MATIndirectTextureOrderEntry
 
-			err = binary.Read(bytes.NewReader(data[offset:]), binary.BigEndian, &texOrderEntry)
+			err = read(data, offset, &texOrderEntry)
 			if err != nil {
 				return err
 			}
@@ -315,7 +315,7 @@ func (r *Root) ParseMAT(data []byte, sectionSize uint32) error {
 		for i := 0; i < BitExtract(matMaterials.BitFlag, 9, 13); i++ {
 			var temp MATTevStageEntry
 
-			err = binary.Read(bytes.NewReader(data[offset:]), binary.BigEndian, &temp)
+			err = read(data, offset, &temp)
 			if err != nil {
 				return err
 			}
@@ -376,7 +376,7 @@ func (r *Root) ParseMAT(data []byte, sectionSize uint32) error {
 		if BitExtract(matMaterials.BitFlag, 8, 8) == 1 {
 			var alphaCompare MatAlphaCompare
 
-			err = binary.Read(bytes.NewReader(data[offset:]), binary.BigEndian, &alphaCompare)
+			err = read(data, offset, &alphaCompare)
 			if err != nil {
 				return err
 			}
@@ -396,7 +396,7 @@ func (r *Root) ParseMAT(data []byte, sectionSize uint32) error {
 		if BitExtract(matMaterials.BitFlag, 7, 7) == 1 {
 			var blendMode MATBlendMode
 
-			err = binary.Read(bytes.NewReader(data[offset:]), binary.BigEndian, &blendMode)
+			err = read(data, offset, &blendMode)
 			if err != nil {
 				return err
 			}
@@ -484,13 +484,10 @@ func (b *BRLYTWriter) WriteMAT(data Root) error {
 	meta := MAT{NumOfMats: uint16(len(data.MAT.Entries))}
 
 	offsets := make([]MATOffset, len(data.MAT.Entries))
-	offsets[0].Offset = uint32(len(data.MAT.Entries)*4 + 12)
 
 	count := 12 + (len(data.MAT.Entries) * 4)
 	for i, entry := range data.MAT.Entries {
-		if i != 0 {
-			offsets[i].Offset = uint32(count)
-		}
+		offsets[i].Offset = uint32(count)
 
 		var name [20]byte
 		copy(name[:], entry.Name)
@@ -504,10 +501,20 @@ func (b *BRLYTWriter) WriteMAT(data Root) error {
 			TevColor2: [4]uint8{entry.TevColor2.R, entry.TevColor2.G, entry.TevColor2.B, entry.TevColor2.A},
 			TevColor3: [4]uint8{entry.TevColor3.R, entry.TevColor3.G, entry.TevColor3.B, entry.TevColor3.A},
 			TevColor4: [4]uint8{entry.TevColor4.R, entry.TevColor4.G, entry.TevColor4.B, entry.TevColor4.A},
-			BitFlag:   entry.BitFlag,
 		}
 
-		err := write(temp, material)
+		bitFlag, err := entry.computeBitFlag()
+		if err != nil {
+			return err
+		}
+
+		if entry.BitFlag != 0 && entry.BitFlag != bitFlag && b.options.Warn != nil {
+			b.options.Warn(ErrMismatchedBitFlag(entry.Name, entry.BitFlag, bitFlag))
+		}
+
+		material.BitFlag = bitFlag
+
+		err = write(temp, material)
 		if err != nil {
 			return err
 		}
@@ -515,22 +522,27 @@ func (b *BRLYTWriter) WriteMAT(data Root) error {
 		count += 64
 
 		for _, texture := range entry.Textures {
-			for i2, s := range data.TXL.TPLName {
-				if texture.Name == s {
-					tex := MATTextureEntry{
-						TexIndex: uint16(i2),
-						SWrap:    texture.SWrap,
-						TWrap:    texture.TWrap,
-					}
-
-					err = write(temp, tex)
-					if err != nil {
-						return err
-					}
-
-					count += 4
-				}
+			var texIndex int
+			if data.TXL != nil {
+				texIndex = slices.Index(data.TXL.TPLName, texture.Name)
+			}
+
+			if data.TXL == nil || texIndex == -1 {
+				return ErrUnknownTexture(entry.Name, texture.Name)
 			}
+
+			tex := MATTextureEntry{
+				TexIndex: uint16(texIndex),
+				SWrap:    texture.SWrap,
+				TWrap:    texture.TWrap,
+			}
+
+			err = write(temp, tex)
+			if err != nil {
+				return err
+			}
+
+			count += 4
 		}
 
 		for _, srt := range entry.SRT {
@@ -582,8 +594,8 @@ func (b *BRLYTWriter) WriteMAT(data Root) error {
 		if entry.MatColor != nil {
 			matColor := MATColor{
 				R: entry.MatColor.R,
-				G: entry.MatColor.B,
-				B: entry.MatColor.G,
+				G: entry.MatColor.G,
+				B: entry.MatColor.B,
 				A: entry.MatColor.A,
 			}
 
@@ -799,6 +811,65 @@ func (b *BRLYTWriter) WriteMAT(data Root) error {
 	return write(b, temp.Bytes())
 }
 
+// bitFlagFields are the counts and presence bits of a material's BitFlag, as
+// the number of bits they occupy and their shift.
+var bitFlagFields = []struct {
+	name  string
+	bits  int
+	shift int
+}{
+	{"textures", 4, 0},
+	{"texture SRTs", 4, 4},
+	{"coord gens", 4, 8},
+	{"swap tables", 1, 12},
+	{"indirect SRTs", 2, 13},
+	{"indirect texture orders", 3, 15},
+	{"TEV stages", 5, 18},
+	{"alpha compares", 1, 23},
+	{"blend modes", 1, 24},
+	{"chan controls", 1, 25},
+	{"material colors", 1, 27},
+}
+
+// computeBitFlag derives the BitFlag of the material from its entries. Bits that do not
+// describe an entry are kept from the supplied BitFlag.
+func (m MATEntries) computeBitFlag() (uint32, error) {
+	counts := []int{
+		len(m.Textures),
+		len(m.SRT),
+		len(m.CoordGen),
+		presence(m.TevSwapMode != nil),
+		len(m.IndirectSRT),
+		len(m.IndirectTextureOrder),
+		len(m.TevStageEntry),
+		presence(m.AlphaCompare != nil),
+		presence(m.BlendMode != nil),
+		presence(m.ChanControl != nil),
+		presence(m.MatColor != nil),
+	}
+
+	bitFlag := m.BitFlag
+	for i, field := range bitFlagFields {
+		mask := uint32(1)<<field.bits - 1
+		if counts[i] > int(mask) {
+			return 0, ErrTooManyMaterialEntries(m.Name, field.name, counts[i], int(mask))
+		}
+
+		bitFlag &^= mask << field.shift
+		bitFlag |= uint32(counts[i]) << field.shift
+	}
+
+	return bitFlag, nil
+}
+
+func presence(present bool) int {
+	if present {
+		return 1
+	}
+
+	return 0
+}
+
 func BitExtract(num uint32, start int, end int) int {
 	if end == 100 {
 		end = start
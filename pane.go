@@ -3,7 +3,6 @@ package brlyt
 import (
 	"bytes"
 	"encoding/binary"
-	"io"
 	"strings"
 )
 
@@ -32,27 +31,19 @@ func (r *Root) ParsePAN(data []byte) (*XMLPane, error) {
 		Height:    pane.Height,
 	}
 
+	xmlData.Attached, err = r.parsePaneAttached()
+	if err != nil {
+		return nil, err
+	}
+
+	// Only the Root Pane is guaranteed to have children, peek to see if this pane does.
+	xmlData.Children, err = r.parseChildrenOf(name)
+	if err != nil {
+		return nil, err
+	}
+
 	if name == "RootPane" {
 		r.RootPane = xmlData
-		r.count--
-
-		_, err = r.reader.Seek(8, io.SeekCurrent)
-		if err != nil {
-			return nil, err
-		}
-
-		r.RootPane.Children, err = r.ParseChildren()
-		if err != nil {
-			return nil, err
-		}
-	} else {
-		// Only the Root Pane is guaranteed to have children, peek to see if this pane does.
-		if r.HasChildren() {
-			xmlData.Children, err = r.ParseChildren()
-			if err != nil {
-				return nil, err
-			}
-		}
 	}
 
 	return &xmlData, nil
@@ -83,11 +74,14 @@ func (r *Root) ParseBND(data []byte) (*XMLPane, error) {
 		Height:    pane.Height,
 	}
 
-	if r.HasChildren() {
-		xmlData.Children, err = r.ParseChildren()
-		if err != nil {
-			return nil, err
-		}
+	xmlData.Attached, err = r.parsePaneAttached()
+	if err != nil {
+		return nil, err
+	}
+
+	xmlData.Children, err = r.parseChildrenOf(name)
+	if err != nil {
+		return nil, err
 	}
 
 	return &xmlData, nil
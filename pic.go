@@ -24,7 +24,7 @@ func (r *Root) ParsePIC(data []byte) (*XMLPIC, error) {
 		offset := 88 + (i * 32)
 
 		var uv UVSet
-		err = binary.Read(bytes.NewReader(data[offset:]), binary.BigEndian, &uv)
+		err = read(data, offset, &uv)
 		if err != nil {
 			return nil, err
 		}
@@ -93,20 +93,28 @@ func (r *Root) ParsePIC(data []byte) (*XMLPIC, error) {
 		UVSets:   &XMLUVSets{Set: uvSets},
 	}
 
-	if r.HasChildren() {
-		xmlData.Children, err = r.ParseChildren()
-		if err != nil {
-			return nil, err
-		}
+	xmlData.Attached, err = r.parsePaneAttached()
+	if err != nil {
+		return nil, err
+	}
+
+	xmlData.Children, err = r.parseChildrenOf(name)
+	if err != nil {
+		return nil, err
 	}
 
 	return &xmlData, nil
 }
 
 func (b *BRLYTWriter) WritePIC(pic XMLPIC) error {
+	var uvSets []XMLUVSet
+	if pic.UVSets != nil {
+		uvSets = pic.UVSets.Set
+	}
+
 	header := SectionHeader{
 		Type: SectionTypePIC,
-		Size: uint32(96 + (32 * len(pic.UVSets.Set))),
+		Size: uint32(96 + (32 * len(uvSets))),
 	}
 	var name [16]byte
 	copy(name[:], pic.Name)
@@ -135,7 +143,7 @@ func (b *BRLYTWriter) WritePIC(pic XMLPIC) error {
 		BottomLeftColor:  [4]uint8{pic.BottomLeftColor.R, pic.BottomLeftColor.G, pic.BottomLeftColor.B, pic.BottomLeftColor.A},
 		BottomRightColor: [4]uint8{pic.BottomRightColor.R, pic.BottomRightColor.G, pic.BottomRightColor.B, pic.BottomRightColor.A},
 		MatIndex:         pic.MatIndex,
-		NumOfUVSets:      uint8(len(pic.UVSets.Set)),
+		NumOfUVSets:      uint8(len(uvSets)),
 	}
 
 	err := write(b, header)
@@ -149,7 +157,7 @@ func (b *BRLYTWriter) WritePIC(pic XMLPIC) error {
 	}
 
 	// Write the UV Sets
-	for _, set := range pic.UVSets.Set {
+	for _, set := range uvSets {
 		uvSet := UVSet{
 			TopLeftS:     set.CoordTL.S,
 			TopLeftT:     set.CoordTL.T,
@@ -0,0 +1,228 @@
+package render
+
+import (
+	brlyt "github.com/WiiLink24/brlytlib"
+)
+
+// Comparisons of a material's alphaCompare.
+const (
+	compareNever = iota
+	compareLess
+	compareEqual
+	compareLessEqual
+	compareGreater
+	compareNotEqual
+	compareGreaterEqual
+	compareAlways
+)
+
+// Operations combining the two alpha comparisons.
+const (
+	alphaOpAnd = iota
+	alphaOpOr
+	alphaOpXor
+	alphaOpXnor
+)
+
+// Blend types of a material's blendMode.
+const (
+	blendNone = iota
+	blendBlend
+	blendLogic
+	blendSubtract
+)
+
+// Blend factors. The colour factors refer to the destination when used for the
+// source, and to the source when used for the destination.
+const (
+	factorZero = iota
+	factorOne
+	factorColor
+	factorInvColor
+	factorSrcAlpha
+	factorInvSrcAlpha
+	factorDstAlpha
+	factorInvDstAlpha
+)
+
+// Logic operations.
+const (
+	logicClear = iota
+	logicAnd
+	logicRevAnd
+	logicCopy
+	logicInvAnd
+	logicNoop
+	logicXor
+	logicOr
+	logicNor
+	logicEquiv
+	logicInv
+	logicRevOr
+	logicInvCopy
+	logicInvOr
+	logicNand
+	logicSet
+)
+
+// defaultBlendMode is used by materials without a blendMode.
+var defaultBlendMode = brlyt.MATBlendMode{
+	Type:        blendBlend,
+	Source:      factorSrcAlpha,
+	Destination: factorInvSrcAlpha,
+	Operator:    logicCopy,
+}
+
+// alphaTest reports whether a fragment with alpha passes the alpha compare of material.
+func alphaTest(material *brlyt.MATEntries, alpha float64) bool {
+	if material == nil || material.AlphaCompare == nil {
+		return true
+	}
+
+	cmp := material.AlphaCompare
+	a := toByte(alpha)
+	pass0 := compareAlpha(cmp.Comp0, a, cmp.Ref0)
+	pass1 := compareAlpha(cmp.Comp1, a, cmp.Ref1)
+
+	switch cmp.AlphaOP {
+	case alphaOpAnd:
+		return pass0 && pass1
+	case alphaOpOr:
+		return pass0 || pass1
+	case alphaOpXor:
+		return pass0 != pass1
+	case alphaOpXnor:
+		return pass0 == pass1
+	}
+
+	return true
+}
+
+func compareAlpha(comparison uint8, alpha uint8, ref uint8) bool {
+	switch comparison {
+	case compareNever:
+		return false
+	case compareLess:
+		return alpha < ref
+	case compareEqual:
+		return alpha == ref
+	case compareLessEqual:
+		return alpha <= ref
+	case compareGreater:
+		return alpha > ref
+	case compareNotEqual:
+		return alpha != ref
+	case compareGreaterEqual:
+		return alpha >= ref
+	}
+
+	return true
+}
+
+// blend draws src onto the pixel at x, y with the blend mode of material.
+//
+// Colours are blended like the console does. The console has no notion of a
+// transparent background though, so alpha is kept as the coverage of the pixel
+// and the result is only exact where something opaque was drawn first.
+func (r *renderer) blend(x int, y int, src rgba, material *brlyt.MATEntries) {
+	mode := defaultBlendMode
+	if material != nil && material.BlendMode != nil {
+		mode = *material.BlendMode
+	}
+
+	src = src.clamp()
+	dst := &r.frame[y*r.width+x]
+	coverage := src[3] + dst[3]*(1-src[3])
+
+	var out rgba
+	switch mode.Type {
+	case blendNone:
+		out = src
+		coverage = src[3]
+	case blendBlend:
+		if coverage == 0 {
+			break
+		}
+
+		// The destination only contributes as much as it covers the pixel.
+		for i := 0; i < 3; i++ {
+			blended := src[i]*blendFactor(mode.Source, src, *dst, *dst, i) + dst[i]*dst[3]*blendFactor(mode.Destination, src, *dst, src, i)
+			out[i] = blended / coverage
+		}
+	case blendLogic:
+		for i := 0; i < 3; i++ {
+			out[i] = float64(logicOp(mode.Operator, toByte(src[i]), toByte(dst[i]))) / 255
+		}
+	case blendSubtract:
+		for i := 0; i < 3; i++ {
+			out[i] = dst[i] - src[i]
+		}
+	default:
+		return
+	}
+
+	out[3] = coverage
+	*dst = out.clamp()
+}
+
+// blendFactor returns component i of a blend factor, where color is the colour
+// that the colour factors refer to.
+func blendFactor(factor uint8, src rgba, dst rgba, color rgba, i int) float64 {
+	switch factor {
+	case factorZero:
+		return 0
+	case factorOne:
+		return 1
+	case factorColor:
+		return color[i]
+	case factorInvColor:
+		return 1 - color[i]
+	case factorSrcAlpha:
+		return src[3]
+	case factorInvSrcAlpha:
+		return 1 - src[3]
+	case factorDstAlpha:
+		return dst[3]
+	case factorInvDstAlpha:
+		return 1 - dst[3]
+	}
+
+	return 0
+}
+
+func logicOp(op uint8, src uint8, dst uint8) uint8 {
+	switch op {
+	case logicClear:
+		return 0
+	case logicAnd:
+		return src & dst
+	case logicRevAnd:
+		return src &^ dst
+	case logicCopy:
+		return src
+	case logicInvAnd:
+		return ^src & dst
+	case logicNoop:
+		return dst
+	case logicXor:
+		return src ^ dst
+	case logicOr:
+		return src | dst
+	case logicNor:
+		return ^(src | dst)
+	case logicEquiv:
+		return ^(src ^ dst)
+	case logicInv:
+		return ^dst
+	case logicRevOr:
+		return src | ^dst
+	case logicInvCopy:
+		return ^src
+	case logicInvOr:
+		return ^src | dst
+	case logicNand:
+		return ^(src & dst)
+	}
+
+	return 0xFF
+}
@@ -0,0 +1,113 @@
+package render
+
+import (
+	"math"
+
+	brlyt "github.com/WiiLink24/brlytlib"
+)
+
+// rgba is a colour with components from 0 to 1. Colours may leave that range
+// while they are being combined.
+type rgba [4]float64
+
+func color8(c brlyt.Color8) rgba {
+	return rgba{float64(c.R) / 255, float64(c.G) / 255, float64(c.B) / 255, float64(c.A) / 255}
+}
+
+func color16(c brlyt.Color16) rgba {
+	return rgba{float64(c.R) / 255, float64(c.G) / 255, float64(c.B) / 255, float64(c.A) / 255}
+}
+
+func (c rgba) mul(d rgba) rgba {
+	return rgba{c[0] * d[0], c[1] * d[1], c[2] * d[2], c[3] * d[3]}
+}
+
+func (c rgba) clamp() rgba {
+	for i := range c {
+		c[i] = min(max(c[i], 0), 1)
+	}
+
+	return c
+}
+
+// quad is a rectangle in the space of a pane, along with what is interpolated
+// across it.
+type quad struct {
+	left   float64
+	top    float64
+	width  float64
+	height float64
+	// colors are the top left, top right, bottom left and bottom right vertex colours.
+	colors [4]brlyt.Color8
+	uvSets []brlyt.XMLUVSet
+}
+
+// fragment is what is known about a pixel of a quad before it is shaded.
+type fragment struct {
+	color     rgba
+	alpha     float64
+	texCoords [][2]float64
+}
+
+// drawQuad draws q transformed by m, shading it with material.
+func (r *renderer) drawQuad(m matrix, q quad, material *brlyt.MATEntries, alpha float64) {
+	ox, oy := m.apply(q.left, q.top)
+	rx, ry := m.apply(q.left+q.width, q.top)
+	bx, by := m.apply(q.left, q.top-q.height)
+	brx, bry := m.apply(q.left+q.width, q.top-q.height)
+
+	// Pixels are mapped back onto the quad through the edges leaving its top left corner.
+	ux, uy := rx-ox, ry-oy
+	vx, vy := bx-ox, by-oy
+	det := ux*vy - uy*vx
+	if math.Abs(det) < 1e-9 {
+		return
+	}
+
+	minX := max(int(math.Floor(min(ox, rx, bx, brx))), 0)
+	maxX := min(int(math.Ceil(max(ox, rx, bx, brx))), r.width)
+	minY := max(int(math.Floor(min(oy, ry, by, bry))), 0)
+	maxY := min(int(math.Ceil(max(oy, ry, by, bry))), r.height)
+
+	var colors [4]rgba
+	for i, c := range q.colors {
+		colors[i] = color8(c)
+		colors[i][3] *= alpha
+	}
+
+	texCoords := make([][2]float64, len(q.uvSets))
+	for y := minY; y < maxY; y++ {
+		for x := minX; x < maxX; x++ {
+			dx, dy := float64(x)+0.5-ox, float64(y)+0.5-oy
+			u := (dx*vy - dy*vx) / det
+			v := (ux*dy - uy*dx) / det
+			if u < 0 || u >= 1 || v < 0 || v >= 1 {
+				continue
+			}
+
+			for i, set := range q.uvSets {
+				texCoords[i] = [2]float64{
+					bilerp(float64(set.CoordTL.S), float64(set.CoordTR.S), float64(set.CoordBL.S), float64(set.CoordBR.S), u, v),
+					bilerp(float64(set.CoordTL.T), float64(set.CoordTR.T), float64(set.CoordBL.T), float64(set.CoordBR.T), u, v),
+				}
+			}
+
+			var color rgba
+			for i := range color {
+				color[i] = bilerp(colors[0][i], colors[1][i], colors[2][i], colors[3][i], u, v)
+			}
+
+			out, ok := r.shade(material, fragment{color: color, alpha: alpha, texCoords: texCoords})
+			if ok {
+				r.blend(x, y, out, material)
+			}
+		}
+	}
+}
+
+// bilerp interpolates between the values at the corners of a quad.
+func bilerp(tl float64, tr float64, bl float64, br float64, u float64, v float64) float64 {
+	top := tl + (tr-tl)*u
+	bottom := bl + (br-bl)*u
+	return top + (bottom-top)*v
+}
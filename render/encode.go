@@ -0,0 +1,341 @@
+package render
+
+import (
+	"bytes"
+	"compress/lzw"
+	"compress/zlib"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"image"
+	"image/color"
+	"image/color/palette"
+	"image/draw"
+	"io"
+	"math"
+	"time"
+)
+
+var (
+	ErrNoFrames            = errors.New("there are no frames to encode")
+	ErrFrameSizeMismatch   = errors.New("frames are not all the same size")
+	ErrFrameCountMismatch  = errors.New("the number of frames added differs from the number declared")
+	ErrFrameTooLargeForGIF = errors.New("frames are too large for a GIF")
+)
+
+// FrameEncoder writes the frames of an animation as they are rendered, so that
+// they never all have to be held at once.
+type FrameEncoder interface {
+	// AddFrame encodes the next frame. Every frame has to be the same size.
+	AddFrame(frame *image.RGBA) error
+	// Close finishes the animation, without closing the writer it was written to.
+	Close() error
+}
+
+// pngSignature starts every PNG file.
+var pngSignature = []byte{0x89, 'P', 'N', 'G', '\r', '\n', 0x1A, '\n'}
+
+const (
+	// pngColorRGBA is the colour type of 8 bit RGBA images.
+	pngColorRGBA = 6
+	// apngDelayDenominator is the fraction of a second frame delays are written in.
+	apngDelayDenominator = 10000
+)
+
+// APNGEncoder writes frames as an animated PNG that loops forever.
+type APNGEncoder struct {
+	w     io.Writer
+	count int
+	delay uint16
+	// bounds are those of the first frame, which every other frame has to match.
+	bounds image.Rectangle
+	added  int
+	// Every fcTL and fdAT chunk is numbered, in a single sequence.
+	sequence uint32
+}
+
+// NewAPNGEncoder returns an encoder writing count frames to w, showing each for
+// delay. The number of frames comes before them in the file, so it has to be
+// known up front.
+func NewAPNGEncoder(w io.Writer, count int, delay time.Duration) (*APNGEncoder, error) {
+	if count <= 0 {
+		return nil, ErrNoFrames
+	}
+
+	return &APNGEncoder{
+		w:     w,
+		count: count,
+		delay: uint16(min(math.Round(delay.Seconds()*apngDelayDenominator), math.MaxUint16)),
+	}, nil
+}
+
+func (e *APNGEncoder) AddFrame(frame *image.RGBA) error {
+	if e.added == e.count {
+		return ErrFrameCountMismatch
+	}
+
+	if e.added == 0 {
+		e.bounds = frame.Bounds()
+		err := e.writeHeader()
+		if err != nil {
+			return err
+		}
+	} else if frame.Bounds().Size() != e.bounds.Size() {
+		return ErrFrameSizeMismatch
+	}
+
+	fctl := make([]byte, 26)
+	binary.BigEndian.PutUint32(fctl[0:], e.sequence)
+	binary.BigEndian.PutUint32(fctl[4:], uint32(e.bounds.Dx()))
+	binary.BigEndian.PutUint32(fctl[8:], uint32(e.bounds.Dy()))
+	binary.BigEndian.PutUint16(fctl[20:], e.delay)
+	binary.BigEndian.PutUint16(fctl[22:], apngDelayDenominator)
+	// Frames are disposed of by leaving them, and replace what is under them
+	// rather than being drawn over it.
+	err := writeChunk(e.w, "fcTL", fctl)
+	if err != nil {
+		return err
+	}
+
+	e.sequence++
+
+	data, err := compressFrame(frame)
+	if err != nil {
+		return err
+	}
+
+	// The first frame is also the image shown by decoders without APNG support.
+	if e.added == 0 {
+		err = writeChunk(e.w, "IDAT", data)
+	} else {
+		err = writeChunk(e.w, "fdAT", binary.BigEndian.AppendUint32(nil, e.sequence), data)
+		e.sequence++
+	}
+	if err != nil {
+		return err
+	}
+
+	e.added++
+	return nil
+}
+
+// writeHeader writes everything before the first frame, which sets the size of the image.
+func (e *APNGEncoder) writeHeader() error {
+	_, err := e.w.Write(pngSignature)
+	if err != nil {
+		return err
+	}
+
+	ihdr := make([]byte, 13)
+	binary.BigEndian.PutUint32(ihdr[0:], uint32(e.bounds.Dx()))
+	binary.BigEndian.PutUint32(ihdr[4:], uint32(e.bounds.Dy()))
+	ihdr[8] = 8
+	ihdr[9] = pngColorRGBA
+	err = writeChunk(e.w, "IHDR", ihdr)
+	if err != nil {
+		return err
+	}
+
+	// The number of frames, then the number of times to play them, where zero loops forever.
+	actl := make([]byte, 8)
+	binary.BigEndian.PutUint32(actl, uint32(e.count))
+	return writeChunk(e.w, "acTL", actl)
+}
+
+// Close writes the end of the image, once every declared frame has been added.
+func (e *APNGEncoder) Close() error {
+	if e.added != e.count {
+		return ErrFrameCountMismatch
+	}
+
+	return writeChunk(e.w, "IEND", nil)
+}
+
+// compressFrame returns the image data of a frame, compressed as PNG stores it.
+// Rows are not filtered.
+func compressFrame(frame *image.RGBA) ([]byte, error) {
+	bounds := frame.Bounds()
+	var buf bytes.Buffer
+	zw := zlib.NewWriter(&buf)
+
+	row := make([]byte, 1+bounds.Dx()*4)
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			c := color.NRGBAModel.Convert(frame.RGBAAt(x, y)).(color.NRGBA)
+			i := 1 + (x-bounds.Min.X)*4
+			row[i], row[i+1], row[i+2], row[i+3] = c.R, c.G, c.B, c.A
+		}
+
+		_, err := zw.Write(row)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	err := zw.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// writeChunk writes a PNG chunk made of the concatenation of data.
+func writeChunk(w io.Writer, name string, data ...[]byte) error {
+	var length int
+	for _, d := range data {
+		length += len(d)
+	}
+
+	header := binary.BigEndian.AppendUint32(nil, uint32(length))
+	header = append(header, name...)
+	_, err := w.Write(header)
+	if err != nil {
+		return err
+	}
+
+	crc := crc32.NewIEEE()
+	crc.Write([]byte(name))
+	for _, d := range data {
+		_, err = w.Write(d)
+		if err != nil {
+			return err
+		}
+
+		crc.Write(d)
+	}
+
+	_, err = w.Write(binary.BigEndian.AppendUint32(nil, crc.Sum32()))
+	return err
+}
+
+const (
+	// gifTransparent is the palette index of the transparent colour. It replaces
+	// the darkest grey, which dithering makes up for with black, so that white
+	// stays in the palette.
+	gifTransparent = 17
+	// gifDisposalBackground clears a frame before the next one is drawn.
+	gifDisposalBackground = 2
+)
+
+// GIFEncoder writes frames as a GIF that loops forever. GIFs only have 256
+// colours and delays in hundredths of a second, so this is a rougher preview than
+// APNGEncoder gives.
+type GIFEncoder struct {
+	w       io.Writer
+	delay   uint16
+	palette color.Palette
+	bounds  image.Rectangle
+	added   int
+}
+
+// NewGIFEncoder returns an encoder writing frames to w, showing each for delay.
+func NewGIFEncoder(w io.Writer, delay time.Duration) *GIFEncoder {
+	// Mostly transparent pixels become the transparent colour.
+	colors := make(color.Palette, len(palette.Plan9))
+	copy(colors, palette.Plan9)
+	colors[gifTransparent] = color.Transparent
+
+	return &GIFEncoder{
+		w:       w,
+		delay:   uint16(min(math.Round(delay.Seconds()*100), math.MaxUint16)),
+		palette: colors,
+	}
+}
+
+func (e *GIFEncoder) AddFrame(frame *image.RGBA) error {
+	if e.added == 0 {
+		e.bounds = frame.Bounds()
+		err := e.writeHeader()
+		if err != nil {
+			return err
+		}
+	} else if frame.Bounds().Size() != e.bounds.Size() {
+		return ErrFrameSizeMismatch
+	}
+
+	paletted := image.NewPaletted(image.Rect(0, 0, e.bounds.Dx(), e.bounds.Dy()), e.palette)
+	draw.FloydSteinberg.Draw(paletted, paletted.Bounds(), frame, frame.Bounds().Min)
+
+	var buf bytes.Buffer
+
+	// The graphic control extension sets the delay, disposal and transparent colour.
+	buf.Write([]byte{0x21, 0xF9, 4, gifDisposalBackground<<2 | 1})
+	buf.Write(binary.LittleEndian.AppendUint16(nil, e.delay))
+	buf.Write([]byte{gifTransparent, 0})
+
+	// The image covers the whole canvas and uses the global palette.
+	buf.WriteByte(0x2C)
+	buf.Write(binary.LittleEndian.AppendUint16(nil, 0))
+	buf.Write(binary.LittleEndian.AppendUint16(nil, 0))
+	buf.Write(binary.LittleEndian.AppendUint16(nil, uint16(e.bounds.Dx())))
+	buf.Write(binary.LittleEndian.AppendUint16(nil, uint16(e.bounds.Dy())))
+	buf.WriteByte(0)
+
+	var compressed bytes.Buffer
+	zw := lzw.NewWriter(&compressed, lzw.LSB, 8)
+	_, err := zw.Write(paletted.Pix)
+	if err != nil {
+		return err
+	}
+
+	err = zw.Close()
+	if err != nil {
+		return err
+	}
+
+	// The compressed pixels are split into blocks of at most 255 bytes, ending
+	// with an empty one.
+	buf.WriteByte(8)
+	for data := compressed.Bytes(); len(data) != 0; {
+		n := min(len(data), 255)
+		buf.WriteByte(byte(n))
+		buf.Write(data[:n])
+		data = data[n:]
+	}
+	buf.WriteByte(0)
+
+	_, err = e.w.Write(buf.Bytes())
+	if err != nil {
+		return err
+	}
+
+	e.added++
+	return nil
+}
+
+// writeHeader writes everything before the first frame, which sets the size of the image.
+func (e *GIFEncoder) writeHeader() error {
+	if e.bounds.Dx() > math.MaxUint16 || e.bounds.Dy() > math.MaxUint16 {
+		return ErrFrameTooLargeForGIF
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString("GIF89a")
+	buf.Write(binary.LittleEndian.AppendUint16(nil, uint16(e.bounds.Dx())))
+	buf.Write(binary.LittleEndian.AppendUint16(nil, uint16(e.bounds.Dy())))
+	// A global palette of 256 colours, with the transparent colour as the background.
+	buf.Write([]byte{0xF7, gifTransparent, 0})
+	for _, c := range e.palette {
+		r, g, b, _ := c.RGBA()
+		buf.Write([]byte{byte(r >> 8), byte(g >> 8), byte(b >> 8)})
+	}
+
+	// The Netscape extension makes the animation loop, where zero loops forever.
+	buf.Write([]byte{0x21, 0xFF, 11})
+	buf.WriteString("NETSCAPE2.0")
+	buf.Write([]byte{3, 1, 0, 0, 0})
+
+	_, err := e.w.Write(buf.Bytes())
+	return err
+}
+
+// Close writes the end of the image, once at least one frame has been added.
+func (e *GIFEncoder) Close() error {
+	if e.added == 0 {
+		return ErrNoFrames
+	}
+
+	_, err := e.w.Write([]byte{0x3B})
+	return err
+}
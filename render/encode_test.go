@@ -0,0 +1,110 @@
+package render
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/color"
+	"image/gif"
+	"image/png"
+	"testing"
+	"time"
+)
+
+func testFrames(count int) []*image.RGBA {
+	frames := make([]*image.RGBA, count)
+	for i := range frames {
+		frames[i] = image.NewRGBA(image.Rect(0, 0, 8, 4))
+		frames[i].SetRGBA(i, 0, color.RGBA{R: 255, A: 255})
+	}
+
+	return frames
+}
+
+func TestAPNGEncoder(t *testing.T) {
+	var buf bytes.Buffer
+	encoder, err := NewAPNGEncoder(&buf, 3, time.Second/60)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, frame := range testFrames(3) {
+		err = encoder.AddFrame(frame)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	err = encoder.AddFrame(testFrames(1)[0])
+	if !errors.Is(err, ErrFrameCountMismatch) {
+		t.Errorf("got error %v adding a frame too many, want ErrFrameCountMismatch", err)
+	}
+
+	err = encoder.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Decoders without APNG support show the first frame.
+	img, err := png.Decode(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if img.Bounds().Dx() != 8 || img.Bounds().Dy() != 4 {
+		t.Errorf("got size %v, want 8x4", img.Bounds().Size())
+	}
+
+	_, err = NewAPNGEncoder(&buf, 0, time.Second)
+	if !errors.Is(err, ErrNoFrames) {
+		t.Errorf("got error %v for no frames, want ErrNoFrames", err)
+	}
+}
+
+func TestGIFEncoder(t *testing.T) {
+	var buf bytes.Buffer
+	encoder := NewGIFEncoder(&buf, time.Second/10)
+	frames := testFrames(3)
+	frames[0].SetRGBA(7, 3, color.RGBA{R: 255, G: 255, B: 255, A: 255})
+	for _, frame := range frames {
+		err := encoder.AddFrame(frame)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	err := encoder.AddFrame(image.NewRGBA(image.Rect(0, 0, 4, 4)))
+	if !errors.Is(err, ErrFrameSizeMismatch) {
+		t.Errorf("got error %v adding a smaller frame, want ErrFrameSizeMismatch", err)
+	}
+
+	err = encoder.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	anim, err := gif.DecodeAll(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(anim.Image) != 3 || anim.Delay[0] != 10 || anim.LoopCount != 0 {
+		t.Errorf("got %d frames of %d hundredths looping %d times, want 3 of 10 looping forever",
+			len(anim.Image), anim.Delay[0], anim.LoopCount)
+	}
+
+	// White has to survive next to the transparent colour.
+	first := anim.Image[0]
+	if got := color.RGBAModel.Convert(first.At(7, 3)); got != (color.RGBA{R: 255, G: 255, B: 255, A: 255}) {
+		t.Errorf("got colour %v for a white pixel, want white", got)
+	}
+
+	if _, _, _, a := first.At(0, 3).RGBA(); a != 0 {
+		t.Errorf("got alpha %d for a transparent pixel, want 0", a>>8)
+	}
+
+	err = NewGIFEncoder(&buf, time.Second).Close()
+	if !errors.Is(err, ErrNoFrames) {
+		t.Errorf("got error %v for no frames, want ErrNoFrames", err)
+	}
+}
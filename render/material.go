@@ -0,0 +1,199 @@
+package render
+
+import (
+	"math"
+
+	brlyt "github.com/WiiLink24/brlytlib"
+)
+
+// Channel sources of a material's chanControl.
+const (
+	sourceRegister = 0
+	sourceVertex   = 1
+)
+
+// Texture coordinate sources and matrices of a material's coordGen.
+const (
+	texCoordSource0 = 4
+	texMatrix0      = 30
+	texMatrixStep   = 3
+)
+
+// Wrap modes of a material's textures.
+const (
+	wrapClamp  = 0
+	wrapRepeat = 1
+	wrapMirror = 2
+)
+
+// shade returns the colour of a fragment drawn with material, and whether it
+// passes the material's alpha compare.
+func (r *renderer) shade(material *brlyt.MATEntries, f fragment) (rgba, bool) {
+	var out rgba
+	switch {
+	case material != nil && len(material.TevStageEntry) != 0:
+		out = r.combine(material, f)
+	case material != nil && len(material.Textures) != 0:
+		// Without stages, textures are mapped between the first two colour
+		// registers and modulated by the rasterized colour.
+		ras := rasterized(material, f)
+		tex := r.sample(material, 0, texCoord(material, 0, f.texCoords))
+		low, high := color16(material.ForeColor), color16(material.BackColor)
+		for i := range out {
+			out[i] = (low[i] + (high[i]-low[i])*tex[i]) * ras[i]
+		}
+	default:
+		out = rasterized(material, f)
+	}
+
+	return out, alphaTest(material, out[3])
+}
+
+// rasterized returns the colour of the lighting channel, which comes from either
+// the vertex colours or the material colour.
+func rasterized(material *brlyt.MATEntries, f fragment) rgba {
+	if material == nil || material.ChanControl == nil {
+		return f.color
+	}
+
+	matColor := rgba{1, 1, 1, 1}
+	if material.MatColor != nil {
+		matColor = color8(*material.MatColor)
+	}
+
+	// Like vertex colours, the material colour fades with the pane.
+	matColor[3] *= f.alpha
+
+	out := f.color
+	if material.ChanControl.ColorMaterialSource == sourceRegister {
+		copy(out[:3], matColor[:3])
+	}
+
+	if material.ChanControl.AlphaMaterialSource == sourceRegister {
+		out[3] = matColor[3]
+	}
+
+	return out
+}
+
+// texCoord returns texture coordinate i of material, generated from the UV sets
+// of the fragment.
+func texCoord(material *brlyt.MATEntries, i int, uvs [][2]float64) [2]float64 {
+	if i >= len(material.CoordGen) {
+		if i < len(uvs) {
+			return uvs[i]
+		}
+
+		return [2]float64{}
+	}
+
+	gen := material.CoordGen[i]
+	var st [2]float64
+	if set := int(gen.Source) - texCoordSource0; set >= 0 && set < len(uvs) {
+		st = uvs[set]
+	}
+
+	srt := (int(gen.MatrixSource) - texMatrix0) / texMatrixStep
+	if gen.MatrixSource < texMatrix0 || srt >= len(material.SRT) {
+		return st
+	}
+
+	return applySRT(material.SRT[srt], st)
+}
+
+// applySRT transforms texture coordinates, rotating and scaling them around the
+// centre of the texture.
+func applySRT(srt brlyt.MATSRT, st [2]float64) [2]float64 {
+	sin, cos := math.Sincos(float64(srt.Rotation) * math.Pi / 180)
+	scaleS, scaleT := float64(srt.XScale), float64(srt.YScale)
+	s, t := st[0]-0.5, st[1]-0.5
+
+	return [2]float64{
+		cos*scaleS*s - sin*scaleT*t + 0.5 + float64(srt.XTrans),
+		sin*scaleS*s + cos*scaleT*t + 0.5 + float64(srt.YTrans),
+	}
+}
+
+// sample returns the colour of texture i of material at st, filtered bilinearly.
+// Missing textures are white.
+func (r *renderer) sample(material *brlyt.MATEntries, i int, st [2]float64) rgba {
+	if i >= len(material.Textures) {
+		return rgba{1, 1, 1, 1}
+	}
+
+	texture := material.Textures[i]
+	img, ok := r.textures[texture.Name]
+	if !ok {
+		return rgba{1, 1, 1, 1}
+	}
+
+	bounds := img.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+	if width == 0 || height == 0 {
+		return rgba{1, 1, 1, 1}
+	}
+
+	x := st[0]*float64(width) - 0.5
+	y := st[1]*float64(height) - 0.5
+	x0, y0 := math.Floor(x), math.Floor(y)
+	fx, fy := x-x0, y-y0
+
+	texel := func(tx int, ty int) rgba {
+		tx = wrap(tx, width, texture.SWrap)
+		ty = wrap(ty, height, texture.TWrap)
+		i := img.PixOffset(bounds.Min.X+tx, bounds.Min.Y+ty)
+		pix := img.Pix[i : i+4 : i+4]
+		return rgba{float64(pix[0]) / 255, float64(pix[1]) / 255, float64(pix[2]) / 255, float64(pix[3]) / 255}
+	}
+
+	ix, iy := int(x0), int(y0)
+	tl, tr := texel(ix, iy), texel(ix+1, iy)
+	bl, br := texel(ix, iy+1), texel(ix+1, iy+1)
+
+	var out rgba
+	for c := range out {
+		out[c] = bilerp(tl[c], tr[c], bl[c], br[c], fx, fy)
+	}
+
+	return out
+}
+
+// textureSize returns the size of texture i of material, which is 1x1 when it
+// is missing so that coordinates stay finite.
+func (r *renderer) textureSize(material *brlyt.MATEntries, i int) (float64, float64) {
+	if i >= len(material.Textures) {
+		return 1, 1
+	}
+
+	img, ok := r.textures[material.Textures[i].Name]
+	if !ok || img.Bounds().Empty() {
+		return 1, 1
+	}
+
+	return float64(img.Bounds().Dx()), float64(img.Bounds().Dy())
+}
+
+// wrap maps a texel coordinate into an image of size texels.
+func wrap(i int, size int, mode uint8) int {
+	switch mode {
+	case wrapRepeat:
+		i %= size
+		if i < 0 {
+			i += size
+		}
+	case wrapMirror:
+		period := size * 2
+		i %= period
+		if i < 0 {
+			i += period
+		}
+
+		if i >= size {
+			i = period - 1 - i
+		}
+	default:
+		i = min(max(i, 0), size-1)
+	}
+
+	return i
+}
@@ -0,0 +1,70 @@
+package render
+
+import (
+	"math"
+
+	brlyt "github.com/WiiLink24/brlytlib"
+)
+
+// matrix is a 3x4 affine transform, like the ones panes are positioned with.
+type matrix [3][4]float64
+
+func identity() matrix {
+	return matrix{
+		{1, 0, 0, 0},
+		{0, 1, 0, 0},
+		{0, 0, 1, 0},
+	}
+}
+
+// mul returns m applied after n.
+func (m matrix) mul(n matrix) matrix {
+	var out matrix
+	for row := 0; row < 3; row++ {
+		for col := 0; col < 4; col++ {
+			out[row][col] = m[row][0]*n[0][col] + m[row][1]*n[1][col] + m[row][2]*n[2][col]
+		}
+
+		out[row][3] += m[row][3]
+	}
+
+	return out
+}
+
+// apply transforms a point on the plane of a pane, dropping its depth since
+// layouts are drawn with an orthographic projection.
+func (m matrix) apply(x float64, y float64) (float64, float64) {
+	return m[0][0]*x + m[0][1]*y + m[0][3], m[1][0]*x + m[1][1]*y + m[1][3]
+}
+
+func translation(x float64, y float64, z float64) matrix {
+	m := identity()
+	m[0][3], m[1][3], m[2][3] = x, y, z
+	return m
+}
+
+func scaling(x float64, y float64) matrix {
+	m := identity()
+	m[0][0], m[1][1] = x, y
+	return m
+}
+
+// rotation returns a rotation around the X, Y and Z axes in that order, with the
+// angles in degrees.
+func rotation(x float64, y float64, z float64) matrix {
+	sx, cx := math.Sincos(x * math.Pi / 180)
+	sy, cy := math.Sincos(y * math.Pi / 180)
+	sz, cz := math.Sincos(z * math.Pi / 180)
+
+	rx := matrix{{1, 0, 0, 0}, {0, cx, -sx, 0}, {0, sx, cx, 0}}
+	ry := matrix{{cy, 0, sy, 0}, {0, 1, 0, 0}, {-sy, 0, cy, 0}}
+	rz := matrix{{cz, -sz, 0, 0}, {sz, cz, 0, 0}, {0, 0, 1, 0}}
+	return rz.mul(ry).mul(rx)
+}
+
+// paneMatrix returns the transform of a pane relative to its parent.
+func paneMatrix(translate brlyt.Coord3D, rotate brlyt.Coord3D, scale brlyt.Coord2D) matrix {
+	return translation(float64(translate.X), float64(translate.Y), float64(translate.Z)).
+		mul(rotation(float64(rotate.X), float64(rotate.Y), float64(rotate.Z))).
+		mul(scaling(float64(scale.X), float64(scale.Y)))
+}
@@ -0,0 +1,341 @@
+// Package render draws layouts on the CPU, giving a preview of how they look on
+// the console.
+package render
+
+import (
+	"image"
+	"image/draw"
+	"math"
+	"path"
+	"strings"
+
+	brlyt "github.com/WiiLink24/brlytlib"
+	"github.com/WiiLink24/brlytlib/brfnt"
+	"github.com/WiiLink24/brlytlib/tpl"
+	"github.com/WiiLink24/brlytlib/u8"
+)
+
+// Pane flags.
+const (
+	flagVisible = 1 << 0
+	// flagInfluencedAlpha makes the children of a pane fade along with it.
+	flagInfluencedAlpha = 1 << 1
+)
+
+// Resources holds the files a layout refers to.
+type Resources struct {
+	// Textures are keyed by their name in txl1.
+	Textures map[string]image.Image
+	// Fonts are keyed by their name in fnl1. Archived fonts have no glyph sheets,
+	// so text using them is only drawn if an unarchived copy is supplied instead.
+	Fonts map[string]*brfnt.Font
+}
+
+// LoadResources decodes every texture and font in archive, keying them by file
+// name as layouts refer to them.
+func LoadResources(archive *u8.Archive) (Resources, error) {
+	resources := Resources{
+		Textures: map[string]image.Image{},
+		Fonts:    map[string]*brfnt.Font{},
+	}
+
+	for _, name := range archive.Files() {
+		ext := strings.ToLower(path.Ext(name))
+		if ext != ".tpl" && ext != ".brfnt" && ext != ".brfna" {
+			continue
+		}
+
+		data, err := archive.File(name)
+		if err != nil {
+			return Resources{}, err
+		}
+
+		if ext != ".tpl" {
+			font, err := brfnt.Parse(data)
+			if err != nil {
+				return Resources{}, err
+			}
+
+			resources.Fonts[path.Base(name)] = font
+			continue
+		}
+
+		img, err := tpl.DecodeImage(data)
+		if err != nil {
+			return Resources{}, err
+		}
+
+		resources.Textures[path.Base(name)] = img
+	}
+
+	return resources, nil
+}
+
+// renderer holds the state of drawing a single layout.
+type renderer struct {
+	layout *brlyt.Root
+	width  int
+	height int
+	// frame holds the colour and coverage of each pixel drawn so far.
+	frame    []rgba
+	textures map[string]*image.NRGBA
+	fonts    map[string]*brfnt.Font
+}
+
+// inheritedAlpha is the alpha that a pane passes down to its descendants.
+type inheritedAlpha struct {
+	influenced bool
+	alpha      float64
+}
+
+// pane holds what every kind of pane has in common.
+type pane struct {
+	kind      brlyt.SectionTypes
+	name      string
+	flag      uint8
+	origin    brlyt.Coord2D
+	alpha     uint8
+	translate brlyt.Coord3D
+	rotate    brlyt.Coord3D
+	scale     brlyt.Coord2D
+	width     float32
+	height    float32
+	children  []brlyt.Children
+}
+
+// Options changes how a layout is drawn.
+type Options struct {
+	// Scale multiplies the size of the canvas and everything drawn on it. Zero
+	// is the same as one.
+	Scale float64
+}
+
+// Render draws layout onto a canvas the size of the layout.
+func Render(layout *brlyt.Root, resources Resources) *image.RGBA {
+	return RenderWithOptions(layout, resources, Options{})
+}
+
+// RenderWithOptions draws layout like Render, changing how with options.
+func RenderWithOptions(layout *brlyt.Root, resources Resources, options Options) *image.RGBA {
+	scale := options.Scale
+	if scale == 0 {
+		scale = 1
+	}
+
+	width := int(math.Round(float64(layout.LYT.Width) * scale))
+	height := int(math.Round(float64(layout.LYT.Height) * scale))
+	r := renderer{
+		layout:   layout,
+		width:    max(width, 0),
+		height:   max(height, 0),
+		textures: map[string]*image.NRGBA{},
+		fonts:    resources.Fonts,
+	}
+	r.frame = make([]rgba, r.width*r.height)
+
+	for name, img := range resources.Textures {
+		r.textures[name] = toNRGBA(img)
+	}
+
+	r.drawPane(paneOf(brlyt.SectionTypePAN, &layout.RootPane), viewMatrix(layout, scale), inheritedAlpha{}, nil)
+
+	return r.image()
+}
+
+// viewMatrix returns the transform from the space of the root pane to a canvas
+// the size of the layout multiplied by scale.
+func viewMatrix(layout *brlyt.Root, scale float64) matrix {
+	// Layouts have Y pointing up, from either the centre or the top left of the screen.
+	view := scaling(scale, -scale)
+	if layout.LYT.Centered != 0 {
+		view = translation(float64(layout.LYT.Width)*scale/2, float64(layout.LYT.Height)*scale/2, 0).mul(view)
+	}
+
+	return view
+}
+
+// image returns the frame as an image.
+func (r *renderer) image() *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, r.width, r.height))
+	for i, c := range r.frame {
+		pix := img.Pix[i*4 : i*4+4 : i*4+4]
+		for j := 0; j < 3; j++ {
+			pix[j] = uint8(math.Round(c[j] * c[3] * 255))
+		}
+
+		pix[3] = uint8(math.Round(c[3] * 255))
+	}
+
+	return img
+}
+
+// drawPane draws a pane with drawSelf, which is given the pane's transform and
+// alpha, and then its children.
+func (r *renderer) drawPane(p pane, parent matrix, inherited inheritedAlpha, drawSelf func(m matrix, alpha float64)) {
+	if p.flag&flagVisible == 0 {
+		return
+	}
+
+	m := parent.mul(paneMatrix(p.translate, p.rotate, p.scale))
+	alpha := float64(p.alpha) / 255
+	if inherited.influenced {
+		alpha *= inherited.alpha
+	}
+
+	if drawSelf != nil {
+		drawSelf(m, alpha)
+	}
+
+	if p.flag&flagInfluencedAlpha != 0 && alpha != 1 {
+		inherited = inheritedAlpha{influenced: true, alpha: alpha}
+	}
+
+	r.drawChildren(p.children, m, inherited)
+}
+
+func (r *renderer) drawChildren(children []brlyt.Children, parent matrix, inherited inheritedAlpha) {
+	for _, child := range children {
+		p, ok := childPane(child)
+		if !ok {
+			continue
+		}
+
+		// Bounding panes are never drawn, but their children are.
+		var drawSelf func(m matrix, alpha float64)
+		switch {
+		case child.PIC != nil:
+			drawSelf = func(m matrix, alpha float64) {
+				r.drawPicture(child.PIC, m, alpha)
+			}
+		case child.TXT != nil:
+			drawSelf = func(m matrix, alpha float64) {
+				r.drawText(child.TXT, m, alpha)
+			}
+		case child.WND != nil:
+			drawSelf = func(m matrix, alpha float64) {
+				r.drawWindow(child.WND, m, alpha)
+			}
+		}
+
+		r.drawPane(p, parent, inherited, drawSelf)
+	}
+}
+
+// childPane returns the pane a child holds, if it holds one rather than a group.
+func childPane(child brlyt.Children) (pane, bool) {
+	switch {
+	case child.Pane != nil:
+		return paneOf(brlyt.SectionTypePAN, child.Pane), true
+	case child.BND != nil:
+		return paneOf(brlyt.SectionTypeBND, child.BND), true
+	case child.PIC != nil:
+		pic := child.PIC
+		return pane{
+			kind:      brlyt.SectionTypePIC,
+			name:      pic.Name,
+			flag:      pic.Flag,
+			origin:    pic.Origin,
+			alpha:     pic.Alpha,
+			translate: pic.Translate,
+			rotate:    pic.Rotate,
+			scale:     pic.Scale,
+			width:     pic.Width,
+			height:    pic.Height,
+			children:  pic.Children,
+		}, true
+	case child.TXT != nil:
+		txt := child.TXT
+		return pane{
+			kind:      brlyt.SectionTypeTXT,
+			name:      txt.Name,
+			flag:      txt.Flag,
+			origin:    txt.Origin,
+			alpha:     txt.Alpha,
+			translate: txt.Translate,
+			rotate:    txt.Rotate,
+			scale:     txt.Scale,
+			width:     txt.Width,
+			height:    txt.Height,
+			children:  txt.Children,
+		}, true
+	case child.WND != nil:
+		wnd := child.WND
+		return pane{
+			kind:      brlyt.SectionTypeWND,
+			name:      wnd.Name,
+			flag:      wnd.Flag,
+			origin:    wnd.Origin,
+			alpha:     wnd.Alpha,
+			translate: wnd.Translate,
+			rotate:    wnd.Rotate,
+			scale:     wnd.Scale,
+			width:     wnd.Width,
+			height:    wnd.Height,
+			children:  wnd.Children,
+		}, true
+	}
+
+	return pane{}, false
+}
+
+func paneOf(kind brlyt.SectionTypes, p *brlyt.XMLPane) pane {
+	return pane{
+		kind:      kind,
+		name:      p.Name,
+		flag:      p.Flag,
+		origin:    p.Origin,
+		alpha:     p.Alpha,
+		translate: p.Translate,
+		rotate:    p.Rotate,
+		scale:     p.Scale,
+		width:     p.Width,
+		height:    p.Height,
+		children:  p.Children,
+	}
+}
+
+// drawPicture draws a PIC pane, which is a single quad.
+func (r *renderer) drawPicture(pic *brlyt.XMLPIC, m matrix, alpha float64) {
+	var uvSets []brlyt.XMLUVSet
+	if pic.UVSets != nil {
+		uvSets = pic.UVSets.Set
+	}
+
+	left, top := rectOrigin(pic.Origin, pic.Width, pic.Height)
+	r.drawQuad(m, quad{
+		left:   left,
+		top:    top,
+		width:  float64(pic.Width),
+		height: float64(pic.Height),
+		colors: [4]brlyt.Color8{pic.TopLeftColor, pic.TopRightColor, pic.BottomLeftColor, pic.BottomRightColor},
+		uvSets: uvSets,
+	}, r.material(pic.MatIndex), alpha)
+}
+
+// rectOrigin returns the top left corner of a pane's rectangle relative to its
+// position, which origin places at a corner, edge or the centre of the rectangle.
+func rectOrigin(origin brlyt.Coord2D, width float32, height float32) (float64, float64) {
+	left := -float64(origin.X) * float64(width) / 2
+	top := float64(origin.Y) * float64(height) / 2
+	return left, top
+}
+
+// material returns the material at index, or nil if there is none.
+func (r *renderer) material(index uint16) *brlyt.MATEntries {
+	if int(index) >= len(r.layout.MAT.Entries) {
+		return nil
+	}
+
+	return &r.layout.MAT.Entries[index]
+}
+
+func toNRGBA(img image.Image) *image.NRGBA {
+	if nrgba, ok := img.(*image.NRGBA); ok {
+		return nrgba
+	}
+
+	bounds := img.Bounds()
+	nrgba := image.NewNRGBA(image.Rect(0, 0, bounds.Dx(), bounds.Dy()))
+	draw.Draw(nrgba, nrgba.Bounds(), img, bounds.Min, draw.Src)
+	return nrgba
+}
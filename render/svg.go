@@ -0,0 +1,150 @@
+package render
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+
+	brlyt "github.com/WiiLink24/brlytlib"
+)
+
+// svgStyle styles the panes of a wireframe by their type. Hidden panes are
+// faded, and bounding panes are dashed as they are never drawn.
+const svgStyle = `
+rect { fill: none; stroke-width: 1; vector-effect: non-scaling-stroke; }
+text { font: 10px sans-serif; }
+.pan1 rect { stroke: #808080; }
+.pan1 text { fill: #808080; }
+.pic1 rect { stroke: #2a7fff; fill: #2a7fff; fill-opacity: 0.08; }
+.pic1 text { fill: #2a7fff; }
+.txt1 rect { stroke: #e09000; fill: #e09000; fill-opacity: 0.08; }
+.txt1 text { fill: #e09000; }
+.wnd1 rect { stroke: #20a040; fill: #20a040; fill-opacity: 0.08; }
+.wnd1 text { fill: #20a040; }
+.bnd1 rect { stroke: #d03030; stroke-dasharray: 4 2; }
+.bnd1 text { fill: #d03030; }
+.hidden { opacity: 0.4; }
+`
+
+type svgDocument struct {
+	XMLName xml.Name      `xml:"http://www.w3.org/2000/svg svg"`
+	Width   float64       `xml:"width,attr"`
+	Height  float64       `xml:"height,attr"`
+	ViewBox string        `xml:"viewBox,attr"`
+	Style   svgStyleSheet `xml:"style"`
+	Panes   []svgGroup    `xml:"g"`
+}
+
+type svgStyleSheet struct {
+	CSS string `xml:",cdata"`
+}
+
+// svgGroup is a single pane, placed by the transform from its own space to the
+// canvas.
+type svgGroup struct {
+	ID        string  `xml:"id,attr"`
+	Class     string  `xml:"class,attr"`
+	Transform string  `xml:"transform,attr"`
+	Rect      svgRect `xml:"rect"`
+	Label     svgText `xml:"text"`
+}
+
+type svgRect struct {
+	X      float64 `xml:"x,attr"`
+	Y      float64 `xml:"y,attr"`
+	Width  float64 `xml:"width,attr"`
+	Height float64 `xml:"height,attr"`
+}
+
+type svgText struct {
+	X         float64 `xml:"x,attr"`
+	Y         float64 `xml:"y,attr"`
+	Transform string  `xml:"transform,attr"`
+	Text      string  `xml:",chardata"`
+}
+
+// EncodeSVG writes a wireframe of layout to w as an SVG the size of the layout.
+// Every pane becomes a group, in the order they are drawn, holding the rectangle
+// the pane covers and its name. Groups are classed by pane type, as well as
+// "hidden" if the pane or one of its parents is hidden.
+func EncodeSVG(w io.Writer, layout *brlyt.Root) error {
+	width, height := float64(layout.LYT.Width), float64(layout.LYT.Height)
+	doc := svgDocument{
+		Width:   width,
+		Height:  height,
+		ViewBox: fmt.Sprintf("0 0 %g %g", width, height),
+		Style:   svgStyleSheet{CSS: svgStyle},
+	}
+
+	ids := map[string]bool{}
+	doc.Panes = addSVGPanes(doc.Panes, ids, paneOf(brlyt.SectionTypePAN, &layout.RootPane), viewMatrix(layout, 1), true)
+
+	_, err := io.WriteString(w, xml.Header)
+	if err != nil {
+		return err
+	}
+
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "\t")
+	err = encoder.Encode(doc)
+	if err != nil {
+		return err
+	}
+
+	_, err = io.WriteString(w, "\n")
+	return err
+}
+
+// addSVGPanes appends the groups of p and its children. ids holds the ids given
+// out so far, as layouts may reuse a pane name while SVG ids have to be unique.
+func addSVGPanes(groups []svgGroup, ids map[string]bool, p pane, parent matrix, visible bool) []svgGroup {
+	m := parent.mul(paneMatrix(p.translate, p.rotate, p.scale))
+	visible = visible && p.flag&flagVisible != 0
+
+	class := p.kind.String()
+	if !visible {
+		class += " hidden"
+	}
+
+	// Later panes with a name already in use get the first free numbered id.
+	id := p.name
+	for i := 2; ids[id]; i++ {
+		id = fmt.Sprintf("%s-%d", p.name, i)
+	}
+	ids[id] = true
+
+	// Panes have Y pointing up, while the rectangle and label expect it down.
+	left, top := rectOrigin(p.origin, p.width, p.height)
+
+	// SVG does not draw rectangles of negative size, so those start from their
+	// other edge instead.
+	rect := svgRect{X: left, Y: top - float64(p.height), Width: float64(p.width), Height: float64(p.height)}
+	if rect.Width < 0 {
+		rect.X, rect.Width = rect.X+rect.Width, -rect.Width
+	}
+
+	if rect.Height < 0 {
+		rect.Y, rect.Height = rect.Y+rect.Height, -rect.Height
+	}
+
+	groups = append(groups, svgGroup{
+		ID:        id,
+		Class:     class,
+		Transform: fmt.Sprintf("matrix(%g %g %g %g %g %g)", m[0][0], m[1][0], m[0][1], m[1][1], m[0][3], m[1][3]),
+		Rect:      rect,
+		Label: svgText{
+			X:         2,
+			Y:         10,
+			Transform: fmt.Sprintf("translate(%g %g) scale(1 -1)", left, top),
+			Text:      p.name,
+		},
+	})
+
+	for _, child := range p.children {
+		if c, ok := childPane(child); ok {
+			groups = addSVGPanes(groups, ids, c, m, visible)
+		}
+	}
+
+	return groups
+}
@@ -0,0 +1,68 @@
+package render
+
+import (
+	"bytes"
+	"encoding/xml"
+	"testing"
+
+	brlyt "github.com/WiiLink24/brlytlib"
+)
+
+func TestEncodeSVG(t *testing.T) {
+	pane := func(name string, width float32, height float32, children ...brlyt.Children) *brlyt.XMLPane {
+		return &brlyt.XMLPane{
+			Name:     name,
+			Flag:     flagVisible,
+			Alpha:    255,
+			Origin:   brlyt.Coord2D{X: 1, Y: 1},
+			Scale:    brlyt.Coord2D{X: 1, Y: 1},
+			Width:    width,
+			Height:   height,
+			Children: children,
+		}
+	}
+
+	layout := &brlyt.Root{
+		LYT: brlyt.LYTNode{Width: 608, Height: 456},
+		RootPane: *pane("RootPane", 608, 456,
+			brlyt.Children{Pane: pane("N_Item", 100, 50,
+				brlyt.Children{Pane: pane("N_Item", 40, 20)},
+			)},
+			brlyt.Children{Pane: pane("N_Item-2", 40, 20)},
+			brlyt.Children{BND: pane("B_Flipped", -40, -20)},
+		),
+	}
+
+	var buf bytes.Buffer
+	err := EncodeSVG(&buf, layout)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var doc svgDocument
+	err = xml.Unmarshal(buf.Bytes(), &doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	wantIDs := []string{"RootPane", "N_Item", "N_Item-2", "N_Item-2-2", "B_Flipped"}
+	if len(doc.Panes) != len(wantIDs) {
+		t.Fatalf("got %d groups, want %d", len(doc.Panes), len(wantIDs))
+	}
+
+	for i, id := range wantIDs {
+		if doc.Panes[i].ID != id {
+			t.Errorf("got id %q for group %d, want %q", doc.Panes[i].ID, i, id)
+		}
+	}
+
+	// The flipped pane covers the same centred rectangle as a 40x20 one.
+	want := svgRect{X: -20, Y: -10, Width: 40, Height: 20}
+	if got := doc.Panes[4].Rect; got != want {
+		t.Errorf("got rectangle %+v for a pane of negative size, want %+v", got, want)
+	}
+
+	if got := doc.Panes[3].Rect; got != want {
+		t.Errorf("got rectangle %+v, want %+v", got, want)
+	}
+}
@@ -0,0 +1,456 @@
+package render
+
+import (
+	"math"
+
+	brlyt "github.com/WiiLink24/brlytlib"
+)
+
+// Colour inputs of a TEV stage.
+const (
+	colorPrev = iota
+	alphaPrev
+	colorReg0
+	alphaReg0
+	colorReg1
+	alphaReg1
+	colorReg2
+	alphaReg2
+	colorTex
+	alphaTex
+	colorRas
+	alphaRas
+	colorOne
+	colorHalf
+	colorKonst
+	colorZero
+)
+
+// Alpha inputs of a TEV stage.
+const (
+	alphaInPrev = iota
+	alphaInReg0
+	alphaInReg1
+	alphaInReg2
+	alphaInTex
+	alphaInRas
+	alphaInKonst
+	alphaInZero
+)
+
+// Operations of a TEV stage. Comparisons add C to D when A compares to B.
+const (
+	opAdd         = 0
+	opSub         = 1
+	opCompR8GT    = 8
+	opCompR8EQ    = 9
+	opCompGR16GT  = 10
+	opCompGR16EQ  = 11
+	opCompBGR24GT = 12
+	opCompBGR24EQ = 13
+	opCompRGB8GT  = 14
+	opCompRGB8EQ  = 15
+)
+
+const (
+	// channelColor0 is the lighting channel a stage rasterizes, and the only one layouts use.
+	channelColor0 = 4
+	// noTexture marks a stage that does not sample a texture.
+	noTexture = 0xFF
+	// numRegisters is the number of colour registers, PREV followed by REG0 to REG2.
+	numRegisters = 4
+)
+
+// Konst selections that pick a fixed fraction rather than a konst colour.
+var konstFractions = [8]float64{1, 7.0 / 8, 3.0 / 4, 5.0 / 8, 1.0 / 2, 3.0 / 8, 1.0 / 4, 1.0 / 8}
+
+// Konst selections of a whole colour, and of a single component. Component
+// selections come in groups of four konst colours, for red, green, blue and alpha.
+const (
+	konstColor0 = 0x0C
+	konstRed0   = 0x10
+)
+
+// defaultSwapTables are the swap tables used by materials without a tevSwapMode.
+var defaultSwapTables = [4][4]uint8{
+	{0, 1, 2, 3},
+	{0, 0, 0, 3},
+	{1, 1, 1, 3},
+	{2, 2, 2, 3},
+}
+
+// tev holds the state of the texture environment while a fragment is combined.
+type tev struct {
+	r         *renderer
+	material  *brlyt.MATEntries
+	fragment  fragment
+	registers [numRegisters]rgba
+	konst     [4]rgba
+	swap      [4][4]uint8
+	indirect  [][3]float64
+	// previous is the texture coordinate of the previous stage, in texels.
+	previous [2]float64
+}
+
+// combine runs the TEV stages of material for a fragment, returning the colour
+// they produce.
+func (r *renderer) combine(material *brlyt.MATEntries, f fragment) rgba {
+	t := tev{
+		r:        r,
+		material: material,
+		fragment: f,
+		konst: [4]rgba{
+			color8(material.TevColor1),
+			color8(material.TevColor2),
+			color8(material.TevColor3),
+			color8(material.TevColor4),
+		},
+		swap: defaultSwapTables,
+	}
+
+	t.registers[1] = color16(material.ForeColor)
+	t.registers[2] = color16(material.BackColor)
+	t.registers[3] = color16(material.ColorReg3)
+
+	if swap := material.TevSwapMode; swap != nil {
+		t.swap = [4][4]uint8{
+			{swap.AR, swap.AG, swap.AB, swap.AA},
+			{swap.BR, swap.BG, swap.BB, swap.BA},
+			{swap.CR, swap.CG, swap.CB, swap.CA},
+			{swap.DR, swap.DG, swap.DB, swap.DA},
+		}
+	}
+
+	t.indirect = make([][3]float64, len(material.IndirectTextureOrder))
+	for i, order := range material.IndirectTextureOrder {
+		t.indirect[i] = t.indirectLookup(order)
+	}
+
+	// The output of the last stage is the output of the whole environment.
+	var out rgba
+	for _, stage := range material.TevStageEntry {
+		out = t.runStage(stage)
+	}
+
+	return out
+}
+
+// runStage evaluates a single stage, returning what it wrote to its registers.
+func (t *tev) runStage(stage brlyt.MATTevStageEntryXML) rgba {
+	tex := rgba{1, 1, 1, 1}
+	if stage.TexMap&0xFF != noTexture && int(stage.TexMap) < len(t.material.Textures) {
+		tex = t.r.sample(t.material, int(stage.TexMap), t.stageCoord(stage))
+	}
+
+	ras := rgba{}
+	if stage.Color == channelColor0 {
+		ras = rasterized(t.material, t.fragment)
+	}
+
+	tex = t.swapped(tex, stage.TexSel)
+	ras = t.swapped(ras, stage.RasSel)
+	konst := t.konstColor(stage.ColorConstantSel)
+	konst[3] = t.konstAlpha(stage.AlphaConstantSel)
+
+	colorIn := func(arg uint8) [3]float64 {
+		var c rgba
+		switch arg {
+		case colorPrev, colorReg0, colorReg1, colorReg2:
+			c = t.registers[arg/2]
+		case alphaPrev, alphaReg0, alphaReg1, alphaReg2:
+			a := t.registers[arg/2][3]
+			c = rgba{a, a, a}
+		case colorTex:
+			c = tex
+		case alphaTex:
+			c = rgba{tex[3], tex[3], tex[3]}
+		case colorRas:
+			c = ras
+		case alphaRas:
+			c = rgba{ras[3], ras[3], ras[3]}
+		case colorOne:
+			c = rgba{1, 1, 1}
+		case colorHalf:
+			c = rgba{0.5, 0.5, 0.5}
+		case colorKonst:
+			c = konst
+		}
+
+		return [3]float64{c[0], c[1], c[2]}
+	}
+
+	alphaIn := func(arg uint8) float64 {
+		switch arg {
+		case alphaInPrev, alphaInReg0, alphaInReg1, alphaInReg2:
+			return t.registers[arg][3]
+		case alphaInTex:
+			return tex[3]
+		case alphaInRas:
+			return ras[3]
+		case alphaInKonst:
+			return konst[3]
+		}
+
+		return 0
+	}
+
+	colorA, colorB := colorIn(stage.ColorA), colorIn(stage.ColorB)
+	colorC, colorD := colorIn(stage.ColorC), colorIn(stage.ColorD)
+	alphaA, alphaB := alphaIn(stage.AlphaA), alphaIn(stage.AlphaB)
+	alphaC, alphaD := alphaIn(stage.AlphaC), alphaIn(stage.AlphaD)
+
+	var out rgba
+	for i := 0; i < 3; i++ {
+		out[i] = combineValue(colorA[i], colorB[i], colorC[i], colorD[i], stage.ColorOP, stage.ColorBias, stage.ColorScale, stage.ColorClamp)
+	}
+
+	if stage.ColorOP >= opCompR8GT && stage.ColorOP < opCompRGB8GT {
+		// Comparisons of packed components apply to the whole colour at once.
+		passed := compare(colorA, colorB, stage.ColorOP)
+		for i := 0; i < 3; i++ {
+			out[i] = colorD[i]
+			if passed {
+				out[i] += colorC[i]
+			}
+
+			out[i] = clampRegister(out[i], stage.ColorClamp)
+		}
+	}
+
+	switch {
+	case stage.AlphaOP >= opCompR8GT && stage.AlphaOP < opCompRGB8GT:
+		// These compare the colour inputs of the stage, adding to the alpha.
+		out[3] = alphaD
+		if compare(colorA, colorB, stage.AlphaOP) {
+			out[3] += alphaC
+		}
+
+		out[3] = clampRegister(out[3], stage.AlphaClamp)
+	default:
+		out[3] = combineValue(alphaA, alphaB, alphaC, alphaD, stage.AlphaOP, stage.AlphaBias, stage.AlphaScale, stage.AlphaClamp)
+	}
+
+	// Colour and alpha can be written to different registers.
+	colorReg, alphaReg := int(stage.ColorRegID)%numRegisters, int(stage.AlphaRegID)%numRegisters
+	copy(t.registers[colorReg][:3], out[:3])
+	t.registers[alphaReg][3] = out[3]
+	return out
+}
+
+// combineValue applies a TEV operation to a single component.
+func combineValue(a float64, b float64, c float64, d float64, op uint8, bias uint8, scale uint8, clamp uint8) float64 {
+	// A, B and C are unsigned 8 bit inputs.
+	a, b, c = clampUnit(a), clampUnit(b), clampUnit(c)
+
+	var out float64
+	switch op {
+	case opAdd, opSub:
+		lerp := a*(1-c) + b*c
+		if op == opSub {
+			lerp = -lerp
+		}
+
+		out = d + lerp
+		switch bias {
+		case 1:
+			out += 0.5
+		case 2:
+			out -= 0.5
+		}
+
+		switch scale {
+		case 1:
+			out *= 2
+		case 2:
+			out *= 4
+		case 3:
+			out /= 2
+		}
+	case opCompRGB8GT:
+		out = d
+		if toByte(a) > toByte(b) {
+			out += c
+		}
+	case opCompRGB8EQ:
+		out = d
+		if toByte(a) == toByte(b) {
+			out += c
+		}
+	default:
+		out = d
+	}
+
+	return clampRegister(out, clamp)
+}
+
+// compare evaluates the comparisons that treat several components of A and B as
+// a single number.
+func compare(a [3]float64, b [3]float64, op uint8) bool {
+	components := 1
+	switch op {
+	case opCompGR16GT, opCompGR16EQ:
+		components = 2
+	case opCompBGR24GT, opCompBGR24EQ:
+		components = 3
+	}
+
+	var va, vb int
+	for i := components - 1; i >= 0; i-- {
+		va = va<<8 | int(toByte(a[i]))
+		vb = vb<<8 | int(toByte(b[i]))
+	}
+
+	// Odd operations test for equality, even ones for greater than.
+	if op%2 == 1 {
+		return va == vb
+	}
+
+	return va > vb
+}
+
+// clampRegister clamps a result to what its register can hold.
+func clampRegister(v float64, clamp uint8) float64 {
+	if clamp != 0 {
+		return clampUnit(v)
+	}
+
+	// Registers hold signed 11 bit values.
+	return min(max(v, -1024.0/255), 1023.0/255)
+}
+
+func clampUnit(v float64) float64 {
+	return min(max(v, 0), 1)
+}
+
+func toByte(v float64) uint8 {
+	return uint8(math.Round(clampUnit(v) * 255))
+}
+
+// swapped reorders the components of c with one of the swap tables.
+func (t *tev) swapped(c rgba, table uint8) rgba {
+	swap := t.swap[table%4]
+	return rgba{c[swap[0]%4], c[swap[1]%4], c[swap[2]%4], c[swap[3]%4]}
+}
+
+// konstColor returns the colour a stage's konst colour selection picks.
+func (t *tev) konstColor(sel uint8) rgba {
+	switch {
+	case sel < uint8(len(konstFractions)):
+		f := konstFractions[sel]
+		return rgba{f, f, f, f}
+	case sel >= konstColor0 && sel < konstRed0:
+		return t.konst[sel-konstColor0]
+	case sel >= konstRed0:
+		component := int(sel-konstRed0) / 4
+		v := t.konst[(sel-konstRed0)%4][component%4]
+		return rgba{v, v, v, v}
+	}
+
+	return rgba{}
+}
+
+// konstAlpha returns the value a stage's konst alpha selection picks.
+func (t *tev) konstAlpha(sel uint8) float64 {
+	switch {
+	case sel < uint8(len(konstFractions)):
+		return konstFractions[sel]
+	case sel >= konstRed0:
+		component := int(sel-konstRed0) / 4
+		return t.konst[(sel-konstRed0)%4][component%4]
+	}
+
+	return 0
+}
+
+// Indirect texture formats, which select how many bits of each component are used.
+var indirectMasks = [4]int{0xFF, 0x1F, 0x0F, 0x07}
+
+// Indirect matrices of a stage, which use the indirect SRTs of the material.
+const (
+	indirectMatrix0 = 1
+	indirectMatrix2 = 3
+)
+
+// indirectWraps are the sizes, in texels, that the wrap modes of a stage wrap
+// its texture coordinate to before it is offset.
+var indirectWraps = [7]float64{0, 256, 128, 64, 32, 16, 0}
+
+// indirectLookup samples an indirect texture, returning the components that
+// offset texture coordinates.
+func (t *tev) indirectLookup(order brlyt.MATIndirectOrderEntryXML) [3]float64 {
+	if int(order.TexMap) >= len(t.material.Textures) {
+		return [3]float64{}
+	}
+
+	st := texCoord(t.material, int(order.TexCoord), t.fragment.texCoords)
+	st[0] /= math.Exp2(float64(order.ScaleS))
+	st[1] /= math.Exp2(float64(order.ScaleT))
+
+	// Offsets come from alpha, blue and green, in that order.
+	c := t.r.sample(t.material, int(order.TexMap), st)
+	return [3]float64{math.Round(c[3] * 255), math.Round(c[2] * 255), math.Round(c[1] * 255)}
+}
+
+// stageCoord returns the texture coordinate a stage samples its texture at,
+// after applying the stage's indirect offset.
+func (t *tev) stageCoord(stage brlyt.MATTevStageEntryXML) [2]float64 {
+	var st [2]float64
+	if stage.TexCoor != noTexture {
+		st = texCoord(t.material, int(stage.TexCoor), t.fragment.texCoords)
+	}
+
+	// Offsets are in texels, so work in those.
+	width, height := t.r.textureSize(t.material, int(stage.TexMap))
+	coord := [2]float64{st[0] * width, st[1] * height}
+	for i, mode := range []uint8{stage.WrapS, stage.WrapT} {
+		if mode == 0 || int(mode) >= len(indirectWraps) {
+			continue
+		}
+
+		size := indirectWraps[mode]
+		if size == 0 {
+			coord[i] = 0
+		} else {
+			coord[i] = math.Mod(coord[i], size)
+		}
+	}
+
+	matrix := int(stage.Matrix)
+	srt := matrix - indirectMatrix0
+	if matrix >= indirectMatrix0 && matrix <= indirectMatrix2 && srt < len(t.material.IndirectSRT) && int(stage.TexID) < len(t.indirect) {
+		var ind [3]float64
+		for i, v := range t.indirect[stage.TexID] {
+			ind[i] = float64(int(v) & indirectMasks[stage.Format%4])
+			if stage.Bias&(1<<i) != 0 {
+				if stage.Format == 0 {
+					ind[i] -= 128
+				} else {
+					ind[i]++
+				}
+			}
+		}
+
+		m := indirectMatrix(t.material.IndirectSRT[srt])
+		for i := range coord {
+			coord[i] += m[i][0]*ind[0] + m[i][1]*ind[1] + m[i][2]*ind[2]
+		}
+	}
+
+	if stage.AddPrevious != 0 {
+		coord[0] += t.previous[0]
+		coord[1] += t.previous[1]
+	}
+
+	t.previous = coord
+	return [2]float64{coord[0] / width, coord[1] / height}
+}
+
+// indirectMatrix returns the matrix an indirect SRT describes.
+func indirectMatrix(srt brlyt.MATSRT) [2][3]float64 {
+	sin, cos := math.Sincos(float64(srt.Rotation) * math.Pi / 180)
+	scaleS, scaleT := float64(srt.XScale), float64(srt.YScale)
+	return [2][3]float64{
+		{cos * scaleS, -sin * scaleT, float64(srt.XTrans)},
+		{sin * scaleS, cos * scaleT, float64(srt.YTrans)},
+	}
+}
@@ -0,0 +1,136 @@
+package render
+
+import (
+	"fmt"
+	"image"
+
+	brlyt "github.com/WiiLink24/brlytlib"
+	"github.com/WiiLink24/brlytlib/brfnt"
+	"github.com/WiiLink24/brlytlib/tpl"
+)
+
+// Line alignments of a TXT pane. Synchronous lines are aligned the same way as
+// the text is positioned.
+const (
+	alignSynchronous = iota
+	alignLeft
+	alignCenter
+	alignRight
+)
+
+// drawText draws a TXT pane with the font it uses. Panes whose font was not
+// supplied, or has no glyph sheets, are not drawn.
+func (r *renderer) drawText(txt *brlyt.XMLTXT, m matrix, alpha float64) {
+	fontName := txt.Font
+	if fontName == "" && r.layout.FNL != nil && len(r.layout.FNL.FNLName) > 0 {
+		fontName = r.layout.FNL.FNLName[0]
+	}
+
+	font, ok := r.fonts[fontName]
+	if !ok || len(font.Sheets) == 0 {
+		return
+	}
+
+	textWidth, textHeight, _ := brlyt.MeasureText(*txt, font)
+	left, top := rectOrigin(txt.Origin, txt.Width, txt.Height)
+
+	// The string origin places the text within the pane like the pane's origin
+	// places the pane.
+	horizontal, vertical := float64(txt.StringOrigin%3), float64(txt.StringOrigin/3)
+	textLeft := left + horizontal*float64(txt.Width-textWidth)/2
+	textTop := top - vertical*float64(txt.Height-textHeight)/2
+
+	alignment := horizontal
+	if txt.LineAlignment >= alignLeft && txt.LineAlignment <= alignRight {
+		alignment = float64(txt.LineAlignment - alignLeft)
+	}
+
+	material := brlyt.MATEntries{BackColor: brlyt.Color16{R: 255, G: 255, B: 255, A: 255}}
+	if mat := r.material(txt.MatIndex); mat != nil {
+		material = *mat
+	}
+
+	scaleX, scaleY := float64(txt.XSize), float64(txt.YSize)
+	if font.Info.Width != 0 {
+		scaleX /= float64(font.Info.Width)
+	}
+
+	if font.Info.Height != 0 {
+		scaleY /= float64(font.Info.Height)
+	}
+
+	lineHeight := float64(font.Info.LineFeed)*scaleY + float64(txt.LineSize)
+	cellHeight := float64(font.Glyph.CellHeight) * scaleY
+	colors := [4]brlyt.Color8{txt.TopColor, txt.TopColor, txt.BottomColor, txt.BottomColor}
+
+	for i, line := range txt.Lines() {
+		single := *txt
+		single.Text = line
+		lineWidth, _, _ := brlyt.MeasureText(single, font)
+
+		x := textLeft + alignment*float64(textWidth-lineWidth)/2
+		y := textTop - float64(i)*lineHeight
+		for j, char := range []rune(line) {
+			if j != 0 {
+				x += float64(txt.CharSize)
+			}
+
+			widths := font.CharWidths(char)
+			if name, ok := r.glyph(fontName, font, char); ok && widths.GlyphWidth != 0 {
+				// Glyphs are drawn without stretching, so only part of the cell is used.
+				s := float32(widths.GlyphWidth) / float32(font.Glyph.CellWidth)
+				material.Textures = []brlyt.MATTexture{{Name: name}}
+				r.drawQuad(m, quad{
+					left:   x + float64(widths.Left)*scaleX,
+					top:    y,
+					width:  float64(widths.GlyphWidth) * scaleX,
+					height: cellHeight,
+					colors: colors,
+					uvSets: []brlyt.XMLUVSet{{
+						CoordTL: brlyt.STCoordinates{S: 0, T: 0},
+						CoordTR: brlyt.STCoordinates{S: s, T: 0},
+						CoordBL: brlyt.STCoordinates{S: 0, T: 1},
+						CoordBR: brlyt.STCoordinates{S: s, T: 1},
+					}},
+				}, &material, alpha)
+			}
+
+			x += float64(widths.CharWidth) * scaleX
+		}
+	}
+}
+
+// glyph returns the name of the texture holding the glyph of font for char,
+// adding it to the textures the first time it is drawn.
+func (r *renderer) glyph(fontName string, font *brfnt.Font, char rune) (string, bool) {
+	// Font names end in an extension, so these never clash with texture names.
+	name := fmt.Sprintf("%s#%d", fontName, font.Index(char))
+	if _, ok := r.textures[name]; ok {
+		return name, true
+	}
+
+	img, ok := font.GlyphImage(char).(*image.NRGBA)
+	if !ok || img == nil {
+		return "", false
+	}
+
+	// Intensity sheets hold how much of each pixel the glyph covers. They are
+	// drawn as white, leaving the colour to the material and vertices.
+	format := tpl.Format(font.Glyph.SheetFormat & 0x7FFF)
+	if format == tpl.I4 || format == tpl.I8 {
+		bounds := img.Bounds()
+		coverage := image.NewNRGBA(image.Rect(0, 0, bounds.Dx(), bounds.Dy()))
+		for y := 0; y < bounds.Dy(); y++ {
+			for x := 0; x < bounds.Dx(); x++ {
+				i := coverage.PixOffset(x, y)
+				coverage.Pix[i], coverage.Pix[i+1], coverage.Pix[i+2] = 0xFF, 0xFF, 0xFF
+				coverage.Pix[i+3] = img.Pix[img.PixOffset(bounds.Min.X+x, bounds.Min.Y+y)]
+			}
+		}
+
+		img = coverage
+	}
+
+	r.textures[name] = img
+	return name, true
+}
@@ -0,0 +1,244 @@
+package render
+
+import (
+	brlyt "github.com/WiiLink24/brlytlib"
+)
+
+// Texture flips of a window frame. Rotations are clockwise.
+const (
+	flipNone = iota
+	flipH
+	flipV
+	flip90
+	flip180
+	flip270
+)
+
+// Frames of a window with eight frames, in the order they are stored. Windows
+// with four frames only have the corners.
+const (
+	frameLT = iota
+	frameRT
+	frameLB
+	frameRB
+	frameL
+	frameR
+	frameT
+	frameB
+)
+
+// windowFrame is the material and flip a part of a window's frame is drawn with.
+type windowFrame struct {
+	material *brlyt.MATEntries
+	flip     uint8
+}
+
+// framePiece is a quad of a window's frame, placed relative to the top left of
+// the window.
+type framePiece struct {
+	frame  windowFrame
+	left   float64
+	top    float64
+	width  float64
+	height float64
+	// right and bottom anchor the texture to those edges of the piece rather
+	// than to the left and top.
+	right  bool
+	bottom bool
+	// stretch fits the texture to the height of the piece.
+	stretch bool
+}
+
+// drawWindow draws a WND pane: its content, followed by its frame.
+//
+// Frames are sized by their textures. A single frame is reused for every corner,
+// flipped to face outwards. Two frames make the left and right sides, four make
+// the corners and eight add the edges between them. The content fills the space
+// between the frames, grown by the window's insets.
+func (r *renderer) drawWindow(wnd *brlyt.XMLWND, m matrix, alpha float64) {
+	var frames []windowFrame
+	if wnd.Materials != nil {
+		for _, mat := range wnd.Materials.Mats {
+			frames = append(frames, windowFrame{material: r.material(mat.MatIndex), flip: mat.Index})
+		}
+	}
+
+	width, height := float64(wnd.Width), float64(wnd.Height)
+	left, top := rectOrigin(wnd.Origin, wnd.Width, wnd.Height)
+	l, t, right, b := r.frameSizes(frames)
+
+	var uvSets []brlyt.XMLUVSet
+	if wnd.UVSets != nil {
+		uvSets = wnd.UVSets.Set
+	}
+
+	// Coordinates 1 to 4 grow the content to the left, right, top and bottom.
+	insetL, insetR := float64(wnd.Coordinate1), float64(wnd.Coordinate2)
+	insetT, insetB := float64(wnd.Coordinate3), float64(wnd.Coordinate4)
+	r.drawQuad(m, quad{
+		left:   left + l - insetL,
+		top:    top - t + insetT,
+		width:  width - l - right + insetL + insetR,
+		height: height - t - b + insetT + insetB,
+		colors: [4]brlyt.Color8{wnd.TopLeftColor, wnd.TopRightColor, wnd.BottomLeftColor, wnd.BottomRightColor},
+		uvSets: uvSets,
+	}, r.material(wnd.MatIndex), alpha)
+
+	white := brlyt.Color8{R: 255, G: 255, B: 255, A: 255}
+	for _, piece := range framePieces(frames, width, height, l, t, right, b) {
+		if piece.width <= 0 || piece.height <= 0 {
+			continue
+		}
+
+		r.drawQuad(m, quad{
+			left:   left + piece.left,
+			top:    top - piece.top,
+			width:  piece.width,
+			height: piece.height,
+			colors: [4]brlyt.Color8{white, white, white, white},
+			uvSets: []brlyt.XMLUVSet{r.pieceUVs(piece)},
+		}, piece.frame.material, alpha)
+	}
+}
+
+// frameSizes returns how far the frame of a window reaches in from its left,
+// top, right and bottom edges.
+func (r *renderer) frameSizes(frames []windowFrame) (float64, float64, float64, float64) {
+	switch len(frames) {
+	case 1:
+		width, height := r.frameTextureSize(frames[0])
+		return width, height, width, height
+	case 2:
+		left, _ := r.frameTextureSize(frames[0])
+		right, _ := r.frameTextureSize(frames[1])
+		return left, 0, right, 0
+	case 4, 8:
+		left, top := r.frameTextureSize(frames[frameLT])
+		right, bottom := r.frameTextureSize(frames[frameRB])
+		return left, top, right, bottom
+	}
+
+	return 0, 0, 0, 0
+}
+
+// frameTextureSize returns the size of the first texture of a frame, which is
+// zero when there is none.
+func (r *renderer) frameTextureSize(frame windowFrame) (float64, float64) {
+	if frame.material == nil || len(frame.material.Textures) == 0 {
+		return 0, 0
+	}
+
+	img, ok := r.textures[frame.material.Textures[0].Name]
+	if !ok {
+		return 0, 0
+	}
+
+	return float64(img.Bounds().Dx()), float64(img.Bounds().Dy())
+}
+
+// framePieces lays out the frame of a window of the given size.
+func framePieces(frames []windowFrame, width float64, height float64, l float64, t float64, r float64, b float64) []framePiece {
+	switch len(frames) {
+	case 1:
+		frame := frames[0]
+		return cornerPieces(
+			windowFrame{material: frame.material, flip: flipNone},
+			windowFrame{material: frame.material, flip: flipH},
+			windowFrame{material: frame.material, flip: flipV},
+			windowFrame{material: frame.material, flip: flip180},
+			width, height, l, t, r, b,
+		)
+	case 2:
+		return []framePiece{
+			{frame: frames[0], width: l, height: height, stretch: true},
+			{frame: frames[1], left: width - r, width: r, height: height, right: true, stretch: true},
+		}
+	case 4:
+		return cornerPieces(frames[frameLT], frames[frameRT], frames[frameLB], frames[frameRB], width, height, l, t, r, b)
+	case 8:
+		return []framePiece{
+			{frame: frames[frameLT], width: l, height: t},
+			{frame: frames[frameT], left: l, width: width - l - r, height: t},
+			{frame: frames[frameRT], left: width - r, width: r, height: t, right: true},
+			{frame: frames[frameL], top: t, width: l, height: height - t - b},
+			{frame: frames[frameR], left: width - r, top: t, width: r, height: height - t - b, right: true},
+			{frame: frames[frameLB], top: height - b, width: l, height: b, bottom: true},
+			{frame: frames[frameB], left: l, top: height - b, width: width - l - r, height: b, bottom: true},
+			{frame: frames[frameRB], left: width - r, top: height - b, width: r, height: b, right: true, bottom: true},
+		}
+	}
+
+	return nil
+}
+
+// cornerPieces lays out a frame made of four corners. Each corner stretches
+// along an edge until it meets the next one, going clockwise.
+func cornerPieces(lt windowFrame, rt windowFrame, lb windowFrame, rb windowFrame, width float64, height float64, l float64, t float64, r float64, b float64) []framePiece {
+	return []framePiece{
+		{frame: lt, width: width - r, height: t},
+		{frame: rt, left: width - r, width: r, height: height - b, right: true},
+		{frame: rb, left: l, top: height - b, width: width - l, height: b, right: true, bottom: true},
+		{frame: lb, top: t, width: l, height: height - t, bottom: true},
+	}
+}
+
+// pieceUVs returns the texture coordinates of a frame piece. Textures keep
+// their size, running from the edge they are anchored to, and are flipped
+// afterwards.
+func (r *renderer) pieceUVs(piece framePiece) brlyt.XMLUVSet {
+	texWidth, texHeight := r.frameTextureSize(piece.frame)
+	if piece.frame.flip == flip90 || piece.frame.flip == flip270 {
+		texWidth, texHeight = texHeight, texWidth
+	}
+
+	if piece.stretch {
+		texHeight = piece.height
+	}
+
+	// The span of the texture that the piece covers, before flipping.
+	s0, s1 := 0.0, 1.0
+	if texWidth != 0 {
+		s1 = piece.width / texWidth
+		if piece.right {
+			s0, s1 = 1-s1, 1
+		}
+	}
+
+	t0, t1 := 0.0, 1.0
+	if texHeight != 0 {
+		t1 = piece.height / texHeight
+		if piece.bottom {
+			t0, t1 = 1-t1, 1
+		}
+	}
+
+	corner := func(s float64, t float64) brlyt.STCoordinates {
+		s, t = flipped(s, t, piece.frame.flip)
+		return brlyt.STCoordinates{S: float32(s), T: float32(t)}
+	}
+
+	return brlyt.XMLUVSet{
+		CoordTL: corner(s0, t0),
+		CoordTR: corner(s1, t0),
+		CoordBL: corner(s0, t1),
+		CoordBR: corner(s1, t1),
+	}
+}
+
+// flipped maps a texture coordinate of a flipped texture back onto the texture.
+func flipped(s float64, t float64, flip uint8) (float64, float64) {
+	switch flip {
+	case flipH:
+		return 1 - s, t
+	case flipV:
+		return s, 1 - t
+	case flip90:
+		return t, 1 - s
+	case flip180:
+		return 1 - s, 1 - t
+	case flip270:
+		return 1 - t, s
+	}
+
+	return s, t
+}
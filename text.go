@@ -0,0 +1,136 @@
+package brlyt
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf16"
+
+	"github.com/WiiLink24/brlytlib/brfnt"
+)
+
+// MeasureText returns the size that the text of txt takes up when drawn with font,
+// along with its number of lines. LineAlignment only moves lines within this size,
+// so it does not affect the result.
+func MeasureText(txt XMLTXT, font *brfnt.Font) (width float32, height float32, lines int) {
+	textLines := txt.Lines()
+	if len(textLines) == 0 {
+		return 0, 0, 0
+	}
+
+	scaleX, scaleY := fontScale(txt, font)
+	for _, line := range textLines {
+		width = max(width, lineWidth(line, font, scaleX, txt.CharSize))
+	}
+
+	lineHeight := float32(font.Info.LineFeed)*scaleY + txt.LineSize
+	height = float32(len(textLines)-1)*lineHeight + float32(font.Info.Height)*scaleY
+	return width, height, len(textLines)
+}
+
+// LintText checks that the text of every TXT pane in layout fits both its pane and
+// its string buffer. fonts holds the fonts the panes use, keyed by their name in fnl1.
+func LintText(layout *Root, fonts map[string]*brfnt.Font) []Issue {
+	var issues []Issue
+	report := func(pane string, format string, args ...any) {
+		issues = append(issues, Issue{Entry: pane, Message: fmt.Sprintf(format, args...)})
+	}
+
+	for _, txt := range layout.textPanes() {
+		// The buffer holds UTF-16 text without its terminator.
+		size := len(utf16.Encode([]rune(txt.text()))) * 2
+		if size > int(txt.MaxStringLength) {
+			report(txt.Name, "text needs %d bytes but max_string_length is %d", size, txt.MaxStringLength)
+		}
+
+		fontName := txt.Font
+		if fontName == "" && layout.FNL != nil && len(layout.FNL.FNLName) > 0 {
+			fontName = layout.FNL.FNLName[0]
+		}
+
+		font, ok := fonts[fontName]
+		if !ok {
+			report(txt.Name, "font %q was not supplied, so the text size was not checked", fontName)
+			continue
+		}
+
+		width, height, _ := MeasureText(*txt, font)
+		if width > txt.Width {
+			report(txt.Name, "text is %g wide but the pane is %g", width, txt.Width)
+		}
+
+		if height > txt.Height {
+			report(txt.Name, "text is %g high but the pane is %g", height, txt.Height)
+		}
+	}
+
+	return issues
+}
+
+// text returns the text of the pane, with escaped line breaks replaced like they
+// are when it is written.
+func (txt XMLTXT) text() string {
+	return strings.Replace(txt.Text, "\\n", "\n", -1)
+}
+
+// Lines splits the text of the pane into the lines it is drawn as.
+func (txt XMLTXT) Lines() []string {
+	text := txt.text()
+	if text == "" {
+		return nil
+	}
+
+	return strings.Split(text, "\n")
+}
+
+// fontScale returns how much the glyphs of font are scaled to draw them at the
+// size of txt.
+func fontScale(txt XMLTXT, font *brfnt.Font) (float32, float32) {
+	scaleX, scaleY := float32(1), float32(1)
+	if font.Info.Width != 0 {
+		scaleX = txt.XSize / float32(font.Info.Width)
+	}
+
+	if font.Info.Height != 0 {
+		scaleY = txt.YSize / float32(font.Info.Height)
+	}
+
+	return scaleX, scaleY
+}
+
+// lineWidth returns the width of a single line, with charSpace between each character.
+func lineWidth(line string, font *brfnt.Font, scaleX float32, charSpace float32) float32 {
+	var width float32
+	for i, char := range []rune(line) {
+		if i != 0 {
+			width += charSpace
+		}
+
+		width += float32(font.CharWidths(char).CharWidth) * scaleX
+	}
+
+	return width
+}
+
+func (r *Root) textPanes() []*XMLTXT {
+	return addTextPanes(nil, r.RootPane.Children)
+}
+
+func addTextPanes(panes []*XMLTXT, children []Children) []*XMLTXT {
+	for _, child := range children {
+		switch {
+		case child.Pane != nil:
+			panes = addTextPanes(panes, child.Pane.Children)
+		case child.BND != nil:
+			panes = addTextPanes(panes, child.BND.Children)
+		case child.PIC != nil:
+			panes = addTextPanes(panes, child.PIC.Children)
+		case child.TXT != nil:
+			panes = append(panes, child.TXT)
+			panes = addTextPanes(panes, child.TXT.Children)
+		case child.WND != nil:
+			panes = addTextPanes(panes, child.WND.Children)
+		}
+	}
+
+	return panes
+}
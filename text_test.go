@@ -0,0 +1,97 @@
+package brlyt
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/WiiLink24/brlytlib/brfnt"
+)
+
+// testFont returns a font of 10x20 glyphs where 'a' and 'b' have glyphs of their
+// own and every other character falls back to glyph 9.
+func testFont() *brfnt.Font {
+	return &brfnt.Font{
+		Info: brfnt.FontInfo{
+			LineFeed:       24,
+			AlternateIndex: 9,
+			DefaultWidth:   brfnt.CharWidths{CharWidth: 10},
+			Width:          10,
+			Height:         20,
+		},
+		Widths: map[uint16]brfnt.CharWidths{
+			0: {CharWidth: 8},
+			1: {CharWidth: 12},
+			9: {CharWidth: 15},
+		},
+		Codes: map[rune]uint16{'a': 0, 'b': 1},
+	}
+}
+
+func TestMeasureText(t *testing.T) {
+	tests := []struct {
+		name   string
+		text   string
+		width  float32
+		height float32
+		lines  int
+	}{
+		{"empty", "", 0, 0, 0},
+		{"single line", "ab", 8*2 + 1 + 12*2, 20, 1},
+		{"line break", "a\nbb", 12*2 + 1 + 12*2, 24 + 2 + 20, 2},
+		{"escaped line break", "a\\nbb", 12*2 + 1 + 12*2, 24 + 2 + 20, 2},
+		{"missing glyph", "az", 8*2 + 1 + 15*2, 20, 1},
+		{"empty lines", "\n\n", 0, 2*(24+2) + 20, 3},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// Glyphs are drawn twice as wide as they are in the font.
+			txt := XMLTXT{Text: test.text, XSize: 20, YSize: 20, CharSize: 1, LineSize: 2}
+			width, height, lines := MeasureText(txt, testFont())
+			if width != test.width || height != test.height || lines != test.lines {
+				t.Errorf("got %gx%g in %d lines, want %gx%g in %d lines",
+					width, height, lines, test.width, test.height, test.lines)
+			}
+		})
+	}
+}
+
+func TestLintText(t *testing.T) {
+	pane := func(name string, text string, maxLength uint16, font string) Children {
+		return Children{TXT: &XMLTXT{
+			Name:            name,
+			Width:           50,
+			Height:          30,
+			MaxStringLength: maxLength,
+			Font:            font,
+			XSize:           10,
+			YSize:           20,
+			Text:            text,
+		}}
+	}
+
+	layout := &Root{
+		FNL: &FNLNames{FNLName: []string{"font.brfnt"}},
+		RootPane: XMLPane{Name: "RootPane", Children: []Children{
+			pane("T_Fits", "ab", 4, ""),
+			pane("T_Buffer", "abab", 6, "font.brfnt"),
+			pane("T_Wide", "bbbbb", 20, ""),
+			pane("T_High", "a\na", 20, ""),
+			{Pane: &XMLPane{Name: "N_Group", Children: []Children{
+				pane("T_NoFont", "a", 2, "other.brfnt"),
+			}}},
+		}},
+	}
+
+	want := []Issue{
+		{Entry: "T_Buffer", Message: "text needs 8 bytes but max_string_length is 6"},
+		{Entry: "T_Wide", Message: "text is 60 wide but the pane is 50"},
+		{Entry: "T_High", Message: "text is 44 high but the pane is 30"},
+		{Entry: "T_NoFont", Message: `font "other.brfnt" was not supplied, so the text size was not checked`},
+	}
+
+	issues := LintText(layout, map[string]*brfnt.Font{"font.brfnt": testFont()})
+	if !slices.Equal(issues, want) {
+		t.Errorf("got issues %v, want %v", issues, want)
+	}
+}
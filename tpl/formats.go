@@ -0,0 +1,475 @@
+package tpl
+
+import (
+	"encoding/binary"
+	"image"
+	"image/color"
+)
+
+// formatInfo describes how the texels of a format are laid out. Images are
+// stored as tiles of blockWidth by blockHeight texels.
+type formatInfo struct {
+	blockWidth   int
+	blockHeight  int
+	bitsPerTexel int
+}
+
+var formats = map[Format]formatInfo{
+	I4:     {8, 8, 4},
+	I8:     {8, 4, 8},
+	IA4:    {8, 4, 8},
+	IA8:    {4, 4, 16},
+	RGB565: {4, 4, 16},
+	RGB5A3: {4, 4, 16},
+	RGBA8:  {4, 4, 32},
+	C4:     {8, 8, 4},
+	C8:     {8, 4, 8},
+	C14X2:  {4, 4, 16},
+	CMPR:   {8, 8, 4},
+}
+
+// paletteSizes is the largest number of palette entries each paletted format can use.
+var paletteSizes = map[Format]int{
+	C4:    16,
+	C8:    256,
+	C14X2: 1 << 14,
+}
+
+func isPaletted(format Format) bool {
+	_, ok := paletteSizes[format]
+	return ok
+}
+
+// DataSize returns the number of bytes taken up by an image's texels.
+func DataSize(format Format, width int, height int) (int, error) {
+	info, ok := formats[format]
+	if !ok {
+		return 0, ErrUnknownFormat(format)
+	}
+
+	blocksX := (width + info.blockWidth - 1) / info.blockWidth
+	blocksY := (height + info.blockHeight - 1) / info.blockHeight
+	return blocksX * blocksY * info.blockWidth * info.blockHeight * info.bitsPerTexel / 8, nil
+}
+
+// forEachBlock calls fn with the top left corner of every block of an image, in
+// the order they are stored.
+func forEachBlock(info formatInfo, width int, height int, fn func(x int, y int)) {
+	for y := 0; y < height; y += info.blockHeight {
+		for x := 0; x < width; x += info.blockWidth {
+			fn(x, y)
+		}
+	}
+}
+
+// DecodePixels decodes the texels of an image. palette is only used by paletted formats.
+func DecodePixels(data []byte, format Format, width int, height int, palette []uint16, paletteFormat PaletteFormat) (*image.NRGBA, error) {
+	info, ok := formats[format]
+	if !ok {
+		return nil, ErrUnknownFormat(format)
+	}
+
+	size, _ := DataSize(format, width, height)
+	if len(data) < size {
+		return nil, ErrOutOfBounds
+	}
+
+	img := image.NewNRGBA(image.Rect(0, 0, width, height))
+	set := func(x int, y int, c color.NRGBA) {
+		// Blocks on the right and bottom edges may be padded past the image.
+		if x < width && y < height {
+			img.SetNRGBA(x, y, c)
+		}
+	}
+
+	var paletteColors []color.NRGBA
+	if isPaletted(format) {
+		paletteColors = make([]color.NRGBA, len(palette))
+		for i, value := range palette {
+			c, err := decodePaletteColor(value, paletteFormat)
+			if err != nil {
+				return nil, err
+			}
+
+			paletteColors[i] = c
+		}
+	}
+
+	blockSize := info.blockWidth * info.blockHeight * info.bitsPerTexel / 8
+	offset := 0
+	forEachBlock(info, width, height, func(bx int, by int) {
+		block := data[offset : offset+blockSize]
+		offset += blockSize
+
+		switch format {
+		case RGBA8:
+			// Alpha and red come first for the whole block, followed by green and blue.
+			for i := 0; i < 16; i++ {
+				set(bx+i%4, by+i/4, color.NRGBA{R: block[i*2+1], G: block[32+i*2], B: block[32+i*2+1], A: block[i*2]})
+			}
+		case CMPR:
+			// Four DXT1 blocks in the order top left, top right, bottom left, bottom right.
+			for sub := 0; sub < 4; sub++ {
+				decodeDXT1(block[sub*8:sub*8+8], func(x int, y int, c color.NRGBA) {
+					set(bx+(sub%2)*4+x, by+(sub/2)*4+y, c)
+				})
+			}
+		default:
+			for i := 0; i < info.blockWidth*info.blockHeight; i++ {
+				value := texel(block, i, info.bitsPerTexel)
+
+				var c color.NRGBA
+				if isPaletted(format) {
+					if format == C14X2 {
+						value &= 0x3FFF
+					}
+
+					if int(value) < len(paletteColors) {
+						c = paletteColors[value]
+					}
+				} else {
+					c = decodeColor(value, format)
+				}
+
+				set(bx+i%info.blockWidth, by+i/info.blockWidth, c)
+			}
+		}
+	})
+
+	return img, nil
+}
+
+// texel reads the i-th texel of a block.
+func texel(block []byte, i int, bits int) uint16 {
+	switch bits {
+	case 4:
+		// The first texel of each byte is in the high nibble.
+		return uint16(block[i/2]>>(4-(i%2)*4)) & 0xF
+	case 8:
+		return uint16(block[i])
+	default:
+		return binary.BigEndian.Uint16(block[i*2:])
+	}
+}
+
+func setTexel(block []byte, i int, bits int, value uint16) {
+	switch bits {
+	case 4:
+		block[i/2] |= byte(value&0xF) << (4 - (i%2)*4)
+	case 8:
+		block[i] = byte(value)
+	default:
+		binary.BigEndian.PutUint16(block[i*2:], value)
+	}
+}
+
+func decodeColor(value uint16, format Format) color.NRGBA {
+	switch format {
+	case I4:
+		i := uint8(value) * 0x11
+		return color.NRGBA{R: i, G: i, B: i, A: 0xFF}
+	case I8:
+		i := uint8(value)
+		return color.NRGBA{R: i, G: i, B: i, A: 0xFF}
+	case IA4:
+		i := uint8(value&0xF) * 0x11
+		return color.NRGBA{R: i, G: i, B: i, A: uint8(value>>4) * 0x11}
+	case IA8:
+		// Alpha is stored in the first byte.
+		i := uint8(value)
+		return color.NRGBA{R: i, G: i, B: i, A: uint8(value >> 8)}
+	case RGB565:
+		return decodeRGB565(value)
+	default:
+		return decodeRGB5A3(value)
+	}
+}
+
+func decodePaletteColor(value uint16, format PaletteFormat) (color.NRGBA, error) {
+	switch format {
+	case PaletteIA8:
+		return decodeColor(value, IA8), nil
+	case PaletteRGB565:
+		return decodeRGB565(value), nil
+	case PaletteRGB5A3:
+		return decodeRGB5A3(value), nil
+	}
+
+	return color.NRGBA{}, ErrUnknownPaletteFormat(format)
+}
+
+func decodeRGB565(value uint16) color.NRGBA {
+	return color.NRGBA{
+		R: expand5(value >> 11),
+		G: expand6(value >> 5),
+		B: expand5(value),
+		A: 0xFF,
+	}
+}
+
+func decodeRGB5A3(value uint16) color.NRGBA {
+	// The top bit picks between opaque RGB555 and RGB444 with 3 bits of alpha.
+	if value&0x8000 != 0 {
+		return color.NRGBA{R: expand5(value >> 10), G: expand5(value >> 5), B: expand5(value), A: 0xFF}
+	}
+
+	return color.NRGBA{
+		R: uint8((value>>8)&0xF) * 0x11,
+		G: uint8((value>>4)&0xF) * 0x11,
+		B: uint8(value&0xF) * 0x11,
+		A: expand3(value >> 12),
+	}
+}
+
+func expand3(value uint16) uint8 {
+	v := uint8(value & 0x7)
+	return v<<5 | v<<2 | v>>1
+}
+
+func expand5(value uint16) uint8 {
+	v := uint8(value & 0x1F)
+	return v<<3 | v>>2
+}
+
+func expand6(value uint16) uint8 {
+	v := uint8(value & 0x3F)
+	return v<<2 | v>>4
+}
+
+// decodeDXT1 decodes a 4x4 block of CMPR texels.
+func decodeDXT1(block []byte, set func(x int, y int, c color.NRGBA)) {
+	c0 := binary.BigEndian.Uint16(block[0:2])
+	c1 := binary.BigEndian.Uint16(block[2:4])
+
+	var palette [4]color.NRGBA
+	palette[0] = decodeRGB565(c0)
+	palette[1] = decodeRGB565(c1)
+	if c0 > c1 {
+		palette[2] = blendDXT(palette[0], palette[1], 5, 3)
+		palette[3] = blendDXT(palette[1], palette[0], 5, 3)
+	} else {
+		palette[2] = blendDXT(palette[0], palette[1], 4, 4)
+		// The fourth colour is transparent.
+		palette[3] = color.NRGBA{R: palette[2].R, G: palette[2].G, B: palette[2].B}
+	}
+
+	for y := 0; y < 4; y++ {
+		row := block[4+y]
+		for x := 0; x < 4; x++ {
+			set(x, y, palette[(row>>(6-x*2))&0x3])
+		}
+	}
+}
+
+// blendDXT mixes a and b in the ratio wa:wb, out of 8, as the console does.
+func blendDXT(a color.NRGBA, b color.NRGBA, wa int, wb int) color.NRGBA {
+	mix := func(x uint8, y uint8) uint8 {
+		return uint8((int(x)*wa + int(y)*wb) >> 3)
+	}
+
+	return color.NRGBA{R: mix(a.R, b.R), G: mix(a.G, b.G), B: mix(a.B, b.B), A: 0xFF}
+}
+
+// EncodePixels encodes img in format. For paletted formats the palette is built
+// from the colours of the image and returned too.
+func EncodePixels(img image.Image, format Format, paletteFormat PaletteFormat) ([]byte, []uint16, error) {
+	info, ok := formats[format]
+	if !ok {
+		return nil, nil, ErrUnknownFormat(format)
+	}
+
+	bounds := img.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+	at := func(x int, y int) color.NRGBA {
+		// Padding texels past the edges repeat the edge.
+		x = min(x, width-1)
+		y = min(y, height-1)
+		return color.NRGBAModel.Convert(img.At(bounds.Min.X+x, bounds.Min.Y+y)).(color.NRGBA)
+	}
+
+	var palette []uint16
+	var indices map[uint16]uint16
+	if isPaletted(format) {
+		indices = map[uint16]uint16{}
+		for y := 0; y < height; y++ {
+			for x := 0; x < width; x++ {
+				value, err := encodePaletteColor(at(x, y), paletteFormat)
+				if err != nil {
+					return nil, nil, err
+				}
+
+				if _, ok := indices[value]; !ok {
+					indices[value] = uint16(len(palette))
+					palette = append(palette, value)
+				}
+			}
+		}
+
+		if len(palette) > paletteSizes[format] {
+			return nil, nil, ErrTooManyColors(format, len(palette), paletteSizes[format])
+		}
+	}
+
+	size, _ := DataSize(format, width, height)
+	data := make([]byte, size)
+	if width == 0 || height == 0 {
+		return data, palette, nil
+	}
+
+	blockSize := info.blockWidth * info.blockHeight * info.bitsPerTexel / 8
+	offset := 0
+	var err error
+	forEachBlock(info, width, height, func(bx int, by int) {
+		block := data[offset : offset+blockSize]
+		offset += blockSize
+
+		switch format {
+		case RGBA8:
+			for i := 0; i < 16; i++ {
+				c := at(bx+i%4, by+i/4)
+				block[i*2], block[i*2+1] = c.A, c.R
+				block[32+i*2], block[32+i*2+1] = c.G, c.B
+			}
+		case CMPR:
+			for sub := 0; sub < 4; sub++ {
+				encodeDXT1(block[sub*8:sub*8+8], func(x int, y int) color.NRGBA {
+					return at(bx+(sub%2)*4+x, by+(sub/2)*4+y)
+				})
+			}
+		default:
+			for i := 0; i < info.blockWidth*info.blockHeight; i++ {
+				c := at(bx+i%info.blockWidth, by+i/info.blockWidth)
+
+				var value uint16
+				if isPaletted(format) {
+					var paletteValue uint16
+					paletteValue, err = encodePaletteColor(c, paletteFormat)
+					value = indices[paletteValue]
+				} else {
+					value = encodeColor(c, format)
+				}
+
+				setTexel(block, i, info.bitsPerTexel, value)
+			}
+		}
+	})
+
+	return data, palette, err
+}
+
+// intensity returns the luma of c.
+func intensity(c color.NRGBA) uint8 {
+	return uint8((299*int(c.R) + 587*int(c.G) + 114*int(c.B) + 500) / 1000)
+}
+
+func encodeColor(c color.NRGBA, format Format) uint16 {
+	switch format {
+	case I4:
+		return uint16(intensity(c) >> 4)
+	case I8:
+		return uint16(intensity(c))
+	case IA4:
+		return uint16(c.A>>4)<<4 | uint16(intensity(c)>>4)
+	case IA8:
+		return uint16(c.A)<<8 | uint16(intensity(c))
+	case RGB565:
+		return encodeRGB565(c)
+	default:
+		return encodeRGB5A3(c)
+	}
+}
+
+func encodePaletteColor(c color.NRGBA, format PaletteFormat) (uint16, error) {
+	switch format {
+	case PaletteIA8:
+		return encodeColor(c, IA8), nil
+	case PaletteRGB565:
+		return encodeRGB565(c), nil
+	case PaletteRGB5A3:
+		return encodeRGB5A3(c), nil
+	}
+
+	return 0, ErrUnknownPaletteFormat(format)
+}
+
+func encodeRGB565(c color.NRGBA) uint16 {
+	return uint16(c.R>>3)<<11 | uint16(c.G>>2)<<5 | uint16(c.B>>3)
+}
+
+func encodeRGB5A3(c color.NRGBA) uint16 {
+	// The largest 3 bit alpha decodes as opaque, so the better colour depth is used instead.
+	if c.A >= 0xE0 {
+		return 0x8000 | uint16(c.R>>3)<<10 | uint16(c.G>>3)<<5 | uint16(c.B>>3)
+	}
+
+	return uint16(c.A>>5)<<12 | uint16(c.R>>4)<<8 | uint16(c.G>>4)<<4 | uint16(c.B>>4)
+}
+
+// encodeDXT1 encodes a 4x4 block of CMPR texels. The block's endpoints are the
+// darkest and brightest opaque texels.
+func encodeDXT1(block []byte, at func(x int, y int) color.NRGBA) {
+	var texels [16]color.NRGBA
+	transparent := false
+	first := true
+	var low, high color.NRGBA
+	for i := range texels {
+		c := at(i%4, i/4)
+		texels[i] = c
+		if c.A < 0x80 {
+			transparent = true
+			continue
+		}
+
+		if first || intensity(c) < intensity(low) {
+			low = c
+		}
+		if first || intensity(c) > intensity(high) {
+			high = c
+		}
+		first = false
+	}
+
+	c0, c1 := encodeRGB565(high), encodeRGB565(low)
+
+	// Four colour mode needs c0 > c1, and three colour mode with transparency c0 <= c1.
+	if transparent == (c0 > c1) {
+		c0, c1 = c1, c0
+	}
+
+	binary.BigEndian.PutUint16(block[0:2], c0)
+	binary.BigEndian.PutUint16(block[2:4], c1)
+
+	var palette [4]color.NRGBA
+	palette[0] = decodeRGB565(c0)
+	palette[1] = decodeRGB565(c1)
+	colors := 4
+	if c0 > c1 {
+		palette[2] = blendDXT(palette[0], palette[1], 5, 3)
+		palette[3] = blendDXT(palette[1], palette[0], 5, 3)
+	} else {
+		palette[2] = blendDXT(palette[0], palette[1], 4, 4)
+		colors = 3
+	}
+
+	for i, c := range texels {
+		index := 3
+		if c.A >= 0x80 || colors == 4 {
+			best := -1
+			for j := 0; j < colors; j++ {
+				distance := colorDistance(c, palette[j])
+				if best == -1 || distance < best {
+					best, index = distance, j
+				}
+			}
+		}
+
+		block[4+i/4] |= byte(index) << (6 - (i%4)*2)
+	}
+}
+
+func colorDistance(a color.NRGBA, b color.NRGBA) int {
+	dr := int(a.R) - int(b.R)
+	dg := int(a.G) - int(b.G)
+	db := int(a.B) - int(b.B)
+	return dr*dr + dg*dg + db*db
+}
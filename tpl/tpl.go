@@ -0,0 +1,401 @@
+// Package tpl reads and writes TPL textures, the image format used by layouts.
+package tpl
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"image"
+	"image/png"
+	"io"
+	"strings"
+
+	"github.com/WiiLink24/brlytlib/internal/binutil"
+)
+
+// Format is the GX texture format of an image.
+type Format uint32
+
+const (
+	I4     Format = 0
+	I8     Format = 1
+	IA4    Format = 2
+	IA8    Format = 3
+	RGB565 Format = 4
+	RGB5A3 Format = 5
+	RGBA8  Format = 6
+	C4     Format = 8
+	C8     Format = 9
+	C14X2  Format = 10
+	CMPR   Format = 14
+)
+
+// PaletteFormat is the format of the colours in the palette of a C4, C8 or C14X2 image.
+type PaletteFormat uint32
+
+const (
+	PaletteIA8    PaletteFormat = 0
+	PaletteRGB565 PaletteFormat = 1
+	PaletteRGB5A3 PaletteFormat = 2
+)
+
+var formatNames = map[Format]string{
+	I4:     "I4",
+	I8:     "I8",
+	IA4:    "IA4",
+	IA8:    "IA8",
+	RGB565: "RGB565",
+	RGB5A3: "RGB5A3",
+	RGBA8:  "RGBA8",
+	C4:     "C4",
+	C8:     "C8",
+	C14X2:  "C14X2",
+	CMPR:   "CMPR",
+}
+
+func (f Format) String() string {
+	if name, ok := formatNames[f]; ok {
+		return name
+	}
+
+	return fmt.Sprintf("Format(%d)", uint32(f))
+}
+
+// ParseFormat returns the format with the given name, such as RGB5A3 or CMPR.
+func ParseFormat(name string) (Format, error) {
+	for format, formatName := range formatNames {
+		if strings.EqualFold(name, formatName) {
+			return format, nil
+		}
+	}
+
+	return 0, ErrUnknownFormatName(name)
+}
+
+const magic = 0x0020AF30
+
+var (
+	ErrInvalidMagic  = errors.New("file is not a TPL")
+	ErrOutOfBounds   = binutil.ErrOutOfBounds
+	ErrNoImages      = errors.New("TPL contains no images")
+	ErrUnknownFormat = func(format Format) error {
+		return fmt.Errorf("unknown texture format %d", uint32(format))
+	}
+	ErrUnknownFormatName = func(name string) error {
+		return fmt.Errorf("unknown texture format %q", name)
+	}
+	ErrUnknownPaletteFormat = func(format PaletteFormat) error {
+		return fmt.Errorf("unknown palette format %d", format)
+	}
+	ErrTooManyColors = func(format Format, colors int, limit int) error {
+		return fmt.Errorf("image has %d colours but %s holds at most %d", colors, format, limit)
+	}
+	ErrImageTooLarge = func(width int, height int) error {
+		return fmt.Errorf("image of %dx%d does not fit in a TPL", width, height)
+	}
+)
+
+// Header represents the header of a TPL
+type Header struct {
+	Magic            uint32
+	NumOfImages      uint32
+	ImageTableOffset uint32
+}
+
+// ImageTableEntry points to the headers of an image and its palette.
+type ImageTableEntry struct {
+	ImageOffset   uint32
+	PaletteOffset uint32
+}
+
+// ImageHeader describes the pixel data of an image and how it is sampled.
+type ImageHeader struct {
+	Height     uint16
+	Width      uint16
+	Format     Format
+	DataOffset uint32
+	WrapS      uint32
+	WrapT      uint32
+	MinFilter  uint32
+	MagFilter  uint32
+	LODBias    float32
+	EdgeLOD    uint8
+	MinLOD     uint8
+	MaxLOD     uint8
+	Unpacked   uint8
+}
+
+// PaletteHeader describes the palette of a C4, C8 or C14X2 image.
+type PaletteHeader struct {
+	NumOfEntries uint16
+	Unpacked     uint8
+	_            uint8
+	Format       PaletteFormat
+	DataOffset   uint32
+}
+
+// Texture is a single image of a TPL along with its sampler settings.
+type Texture struct {
+	Format Format
+	// PaletteFormat is only used by C4, C8 and C14X2 images.
+	PaletteFormat PaletteFormat
+	Image         image.Image
+
+	WrapS     uint32
+	WrapT     uint32
+	MinFilter uint32
+	MagFilter uint32
+	LODBias   float32
+	EdgeLOD   uint8
+	MinLOD    uint8
+	MaxLOD    uint8
+}
+
+func init() {
+	image.RegisterFormat("tpl", "\x00\x20\xaf\x30", decodeImage, decodeConfig)
+}
+
+// Decode parses every image in a TPL.
+func Decode(data []byte) ([]Texture, error) {
+	var header Header
+	err := binutil.Read(data, 0, &header)
+	if err != nil {
+		return nil, err
+	}
+
+	if header.Magic != magic {
+		return nil, ErrInvalidMagic
+	}
+
+	// Each image takes up a table entry, so anything larger cannot be real.
+	if int64(header.NumOfImages)*8 > int64(len(data)) {
+		return nil, ErrOutOfBounds
+	}
+
+	textures := make([]Texture, header.NumOfImages)
+	for i := range textures {
+		var entry ImageTableEntry
+		err = binutil.Read(data, int(header.ImageTableOffset)+(i*8), &entry)
+		if err != nil {
+			return nil, err
+		}
+
+		var imageHeader ImageHeader
+		err = binutil.Read(data, int(entry.ImageOffset), &imageHeader)
+		if err != nil {
+			return nil, err
+		}
+
+		texture := Texture{
+			Format:    imageHeader.Format,
+			WrapS:     imageHeader.WrapS,
+			WrapT:     imageHeader.WrapT,
+			MinFilter: imageHeader.MinFilter,
+			MagFilter: imageHeader.MagFilter,
+			LODBias:   imageHeader.LODBias,
+			EdgeLOD:   imageHeader.EdgeLOD,
+			MinLOD:    imageHeader.MinLOD,
+			MaxLOD:    imageHeader.MaxLOD,
+		}
+
+		var palette []uint16
+		if entry.PaletteOffset != 0 {
+			var paletteHeader PaletteHeader
+			err = binutil.Read(data, int(entry.PaletteOffset), &paletteHeader)
+			if err != nil {
+				return nil, err
+			}
+
+			palette = make([]uint16, paletteHeader.NumOfEntries)
+			err = binutil.Read(data, int(paletteHeader.DataOffset), palette)
+			if err != nil {
+				return nil, err
+			}
+
+			texture.PaletteFormat = paletteHeader.Format
+		}
+
+		size, err := DataSize(imageHeader.Format, int(imageHeader.Width), int(imageHeader.Height))
+		if err != nil {
+			return nil, err
+		}
+
+		start := int(imageHeader.DataOffset)
+		if start < 0 || start+size > len(data) {
+			return nil, ErrOutOfBounds
+		}
+
+		texture.Image, err = DecodePixels(data[start:start+size], imageHeader.Format, int(imageHeader.Width), int(imageHeader.Height), palette, texture.PaletteFormat)
+		if err != nil {
+			return nil, err
+		}
+
+		textures[i] = texture
+	}
+
+	return textures, nil
+}
+
+// DecodeImage returns the first image of a TPL.
+func DecodeImage(data []byte) (image.Image, error) {
+	textures, err := Decode(data)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(textures) == 0 {
+		return nil, ErrNoImages
+	}
+
+	return textures[0].Image, nil
+}
+
+func decodeImage(r io.Reader) (image.Image, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	return DecodeImage(data)
+}
+
+func decodeConfig(r io.Reader) (image.Config, error) {
+	img, err := decodeImage(r)
+	if err != nil {
+		return image.Config{}, err
+	}
+
+	return image.Config{
+		ColorModel: img.ColorModel(),
+		Width:      img.Bounds().Dx(),
+		Height:     img.Bounds().Dy(),
+	}, nil
+}
+
+// Encode writes textures as a TPL.
+func Encode(textures []Texture) ([]byte, error) {
+	headerSize := binary.Size(Header{})
+	tableSize := len(textures) * binary.Size(ImageTableEntry{})
+	imageHeaderSize := binary.Size(ImageHeader{})
+	paletteHeaderSize := binary.Size(PaletteHeader{})
+
+	// Encode the pixel data up front so that the headers can point to it.
+	pixels := make([][]byte, len(textures))
+	palettes := make([][]uint16, len(textures))
+	headersSize := 0
+	for i, texture := range textures {
+		var err error
+		pixels[i], palettes[i], err = EncodePixels(texture.Image, texture.Format, texture.PaletteFormat)
+		if err != nil {
+			return nil, err
+		}
+
+		headersSize += imageHeaderSize
+		if isPaletted(texture.Format) {
+			headersSize += paletteHeaderSize
+		}
+	}
+
+	table := make([]ImageTableEntry, len(textures))
+	headers := bytes.NewBuffer(nil)
+	contents := bytes.NewBuffer(nil)
+
+	// Image data is aligned to 32 bytes, like the console expects.
+	dataStart := binutil.Align(headerSize+tableSize+headersSize, 32)
+	for i, texture := range textures {
+		bounds := texture.Image.Bounds()
+		if bounds.Dx() > 0xFFFF || bounds.Dy() > 0xFFFF {
+			return nil, ErrImageTooLarge(bounds.Dx(), bounds.Dy())
+		}
+
+		if isPaletted(texture.Format) {
+			table[i].PaletteOffset = uint32(headerSize + tableSize + headers.Len())
+			paletteHeader := PaletteHeader{
+				NumOfEntries: uint16(len(palettes[i])),
+				Format:       texture.PaletteFormat,
+				DataOffset:   uint32(dataStart + contents.Len()),
+			}
+
+			err := binary.Write(headers, binary.BigEndian, paletteHeader)
+			if err != nil {
+				return nil, err
+			}
+
+			err = binary.Write(contents, binary.BigEndian, palettes[i])
+			if err != nil {
+				return nil, err
+			}
+
+			binutil.PadTo(contents, 32)
+		}
+
+		table[i].ImageOffset = uint32(headerSize + tableSize + headers.Len())
+		imageHeader := ImageHeader{
+			Height:     uint16(bounds.Dy()),
+			Width:      uint16(bounds.Dx()),
+			Format:     texture.Format,
+			DataOffset: uint32(dataStart + contents.Len()),
+			WrapS:      texture.WrapS,
+			WrapT:      texture.WrapT,
+			MinFilter:  texture.MinFilter,
+			MagFilter:  texture.MagFilter,
+			LODBias:    texture.LODBias,
+			EdgeLOD:    texture.EdgeLOD,
+			MinLOD:     texture.MinLOD,
+			MaxLOD:     texture.MaxLOD,
+		}
+
+		err := binary.Write(headers, binary.BigEndian, imageHeader)
+		if err != nil {
+			return nil, err
+		}
+
+		contents.Write(pixels[i])
+		binutil.PadTo(contents, 32)
+	}
+
+	file := bytes.NewBuffer(nil)
+	header := Header{
+		Magic:            magic,
+		NumOfImages:      uint32(len(textures)),
+		ImageTableOffset: uint32(headerSize),
+	}
+
+	for _, data := range []any{header, table, headers.Bytes()} {
+		err := binary.Write(file, binary.BigEndian, data)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	binutil.PadTo(file, 32)
+	file.Write(contents.Bytes())
+	return file.Bytes(), nil
+}
+
+// EncodeImage writes img as a TPL holding a single image in format.
+// Paletted formats use an RGB5A3 palette.
+func EncodeImage(img image.Image, format Format) ([]byte, error) {
+	return Encode([]Texture{{Format: format, PaletteFormat: PaletteRGB5A3, Image: img}})
+}
+
+// FromPNG converts a PNG to a TPL holding a single image in format.
+func FromPNG(r io.Reader, format Format) ([]byte, error) {
+	img, err := png.Decode(r)
+	if err != nil {
+		return nil, err
+	}
+
+	return EncodeImage(img, format)
+}
+
+// ToPNG writes the first image of a TPL to w as a PNG.
+func ToPNG(w io.Writer, data []byte) error {
+	img, err := DecodeImage(data)
+	if err != nil {
+		return err
+	}
+
+	return png.Encode(w, img)
+}
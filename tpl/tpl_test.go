@@ -0,0 +1,170 @@
+package tpl
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"image"
+	"image/color"
+	"testing"
+)
+
+// testWidth and testHeight are not multiples of any block size, so every format
+// has partial blocks on the right and bottom edges.
+const (
+	testWidth  = 13
+	testHeight = 10
+)
+
+func testImage(pixel func(x int, y int) color.NRGBA) *image.NRGBA {
+	img := image.NewNRGBA(image.Rect(0, 0, testWidth, testHeight))
+	for y := 0; y < testHeight; y++ {
+		for x := 0; x < testWidth; x++ {
+			img.SetNRGBA(x, y, pixel(x, y))
+		}
+	}
+
+	return img
+}
+
+func gray(i uint8, a uint8) color.NRGBA {
+	return color.NRGBA{R: i, G: i, B: i, A: a}
+}
+
+func TestEncodeDecode(t *testing.T) {
+	tests := []struct {
+		format        Format
+		paletteFormat PaletteFormat
+		// pixel only returns colours the format can hold, unless maxError allows for loss.
+		pixel    func(x int, y int) color.NRGBA
+		maxError int
+	}{
+		{format: I4, pixel: func(x, y int) color.NRGBA { return gray(uint8((x+y)%16)*0x11, 0xFF) }},
+		{format: I8, pixel: func(x, y int) color.NRGBA { return gray(uint8(x*19+y), 0xFF) }},
+		{format: IA4, pixel: func(x, y int) color.NRGBA { return gray(uint8(x%16)*0x11, uint8(y%16)*0x11) }},
+		{format: IA8, pixel: func(x, y int) color.NRGBA { return gray(uint8(x*19+y), uint8(255-x*y)) }},
+		{format: RGB565, pixel: func(x, y int) color.NRGBA {
+			return color.NRGBA{R: uint8(x * 19), G: uint8(y * 25), B: uint8(x * y), A: 0xFF}
+		}, maxError: 7},
+		{format: RGB5A3, pixel: func(x, y int) color.NRGBA {
+			// Opaque texels have 5 bits per channel, others 4 bits and 3 bits of alpha.
+			if x%2 == 0 {
+				return color.NRGBA{R: expand5(uint16(x)), G: expand5(uint16(y)), B: expand5(uint16(x + y)), A: 0xFF}
+			}
+
+			return color.NRGBA{R: uint8(x) * 0x11, G: uint8(y) * 0x11, B: uint8(x^y) * 0x11, A: expand3(uint16(y % 7))}
+		}},
+		{format: RGBA8, pixel: func(x, y int) color.NRGBA {
+			return color.NRGBA{R: uint8(x * 19), G: uint8(y * 25), B: uint8(x * y), A: uint8(x*y + 1)}
+		}},
+		{format: C4, paletteFormat: PaletteRGB5A3, pixel: func(x, y int) color.NRGBA {
+			return color.NRGBA{R: expand5(uint16(x % 4)), G: expand5(uint16(y % 4)), B: 0xFF, A: 0xFF}
+		}},
+		{format: C8, paletteFormat: PaletteIA8, pixel: func(x, y int) color.NRGBA { return gray(uint8(x*19+y), uint8(255-x)) }},
+		{format: C14X2, paletteFormat: PaletteRGB565, pixel: func(x, y int) color.NRGBA {
+			return color.NRGBA{R: expand5(uint16(x)), G: expand6(uint16(y * 5)), B: expand5(uint16(x + y)), A: 0xFF}
+		}},
+		{format: CMPR, pixel: func(x, y int) color.NRGBA {
+			// Each block can only hold colours between two endpoints, so this is a single gradient.
+			t := x + y
+			return color.NRGBA{R: uint8(t * 10), G: uint8(t*5 + 40), B: uint8(200 - t*8), A: 0xFF}
+		}, maxError: 16},
+	}
+
+	for _, test := range tests {
+		t.Run(test.format.String(), func(t *testing.T) {
+			want := testImage(test.pixel)
+			data, err := Encode([]Texture{{Format: test.format, PaletteFormat: test.paletteFormat, Image: want, WrapS: 1, MaxLOD: 2}})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			textures, err := Decode(data)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if len(textures) != 1 {
+				t.Fatalf("got %d textures, want 1", len(textures))
+			}
+
+			texture := textures[0]
+			if texture.Format != test.format || texture.PaletteFormat != test.paletteFormat || texture.WrapS != 1 || texture.MaxLOD != 2 {
+				t.Errorf("got texture settings %+v", texture)
+			}
+
+			got := texture.Image
+			if got.Bounds() != want.Bounds() {
+				t.Fatalf("got bounds %v, want %v", got.Bounds(), want.Bounds())
+			}
+
+			for y := 0; y < testHeight; y++ {
+				for x := 0; x < testWidth; x++ {
+					g := color.NRGBAModel.Convert(got.At(x, y)).(color.NRGBA)
+					w := want.NRGBAAt(x, y)
+					if channelError(g, w) > test.maxError {
+						t.Fatalf("pixel (%d, %d) is %v, want %v", x, y, g, w)
+					}
+				}
+			}
+		})
+	}
+}
+
+func channelError(a color.NRGBA, b color.NRGBA) int {
+	diff := func(x uint8, y uint8) int {
+		return max(int(x)-int(y), int(y)-int(x))
+	}
+
+	return max(diff(a.R, b.R), diff(a.G, b.G), diff(a.B, b.B), diff(a.A, b.A))
+}
+
+func TestDecodeImageRegistered(t *testing.T) {
+	data, err := EncodeImage(testImage(func(x, y int) color.NRGBA { return gray(uint8(x), 0xFF) }), I8)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, name, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if name != "tpl" {
+		t.Errorf("got format %q, want tpl", name)
+	}
+}
+
+func TestDecodeOutOfBounds(t *testing.T) {
+	img := testImage(func(x, y int) color.NRGBA { return gray(uint8(x%4)*0x11, 0xFF) })
+	data, err := EncodeImage(img, C4)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The only image table entry follows the 12 byte header.
+	imageOffset := int(binary.BigEndian.Uint32(data[12:]))
+	paletteOffset := int(binary.BigEndian.Uint32(data[16:]))
+
+	tests := []struct {
+		name   string
+		offset int
+	}{
+		{"image data offset", imageOffset + 8},
+		{"image header offset", 12},
+		{"palette header offset", 16},
+		{"palette data offset", paletteOffset + 8},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			corrupt := bytes.Clone(data)
+			binary.BigEndian.PutUint32(corrupt[test.offset:], 0xFFFFFF00)
+
+			_, err := Decode(corrupt)
+			if !errors.Is(err, ErrOutOfBounds) {
+				t.Errorf("got error %v, want ErrOutOfBounds", err)
+			}
+		})
+	}
+}
@@ -3,6 +3,7 @@ package brlyt
 import (
 	"bytes"
 	"encoding/binary"
+	"slices"
 	"strings"
 )
 
@@ -33,7 +34,7 @@ func (r *Root) ParseTXL(data []byte, sectionSize uint32) error {
 		var tplTable TPLOffSet
 		offset := 4 + (i * 8)
 
-		err = binary.Read(bytes.NewReader(data[offset:]), binary.BigEndian, &tplTable)
+		err = read(data, offset, &tplTable)
 		if err != nil {
 			return err
 		}
@@ -48,7 +49,13 @@ func (r *Root) ParseTXL(data []byte, sectionSize uint32) error {
 
 	// Now that we have the offsets, retrieve the TPL names.
 	for i := 0; i < int(txl.NumOfTPL); i++ {
-		tplName := string(data[tplOffsets[i]:tplOffsets[i+1]])
+		start, end := int(tplOffsets[i]), int(tplOffsets[i+1])
+		err = checkBounds("texture name", start, end-start, len(data))
+		if err != nil {
+			return err
+		}
+
+		tplName := string(data[start:end])
 
 		// Strip the null terminator
 		tplName = strings.Replace(tplName, "\x00", "", -1)
@@ -121,3 +128,26 @@ func (b *BRLYTWriter) WriteTXL(data Root) error {
 
 	return write(b, sectionWriter.Bytes())
 }
+
+// textureNames returns the txl1 names with every texture that a material references
+// but is missing from the list appended to the end.
+func (r *Root) textureNames() *TPLNames {
+	var names []string
+	if r.TXL != nil {
+		names = append(names, r.TXL.TPLName...)
+	}
+
+	for _, entry := range r.MAT.Entries {
+		for _, texture := range entry.Textures {
+			if !slices.Contains(names, texture.Name) {
+				names = append(names, texture.Name)
+			}
+		}
+	}
+
+	if names == nil && r.TXL == nil {
+		return nil
+	}
+
+	return &TPLNames{TPLName: names}
+}
@@ -3,6 +3,7 @@ package brlyt
 import (
 	"bytes"
 	"encoding/binary"
+	"slices"
 	"strings"
 	"unicode/utf16"
 )
@@ -18,11 +19,17 @@ func (r *Root) ParseTXT(data []byte, sectionSize uint32) (*XMLTXT, error) {
 	name := strings.Replace(string(text.PaneName[:]), "\x00", "", -1)
 	userData := strings.Replace(string(text.UserData[:]), "\x00", "", -1)
 
-	utf16String := data[text.TextOffset-8 : sectionSize-8]
+	textOffset := int(text.TextOffset) - 8
+	err = checkBounds("text", textOffset, len(data)-textOffset, len(data))
+	if err != nil {
+		return nil, err
+	}
+
+	utf16String := data[textOffset:]
 
 	// Convert the UTF-16 string to UTF-8
 	var full []uint16
-	for i := 0; i < len(utf16String); i += 2 {
+	for i := 0; i+1 < len(utf16String); i += 2 {
 		current := binary.BigEndian.Uint16([]byte{utf16String[i], utf16String[i+1]})
 		if current == 0 {
 			// Our string was terminated
@@ -34,6 +41,15 @@ func (r *Root) ParseTXT(data []byte, sectionSize uint32) (*XMLTXT, error) {
 	// Strip null bytes
 	decodedString := strings.Replace(string(utf16.Decode(full)), "\x00", "", -1)
 
+	var font string
+	if r.FNL != nil {
+		if int(text.FontIndex) >= len(r.FNL.FNLName) {
+			return nil, ErrInvalidFontIndex(text.FontIndex, len(r.FNL.FNLName))
+		}
+
+		font = r.FNL.FNLName[text.FontIndex]
+	}
+
 	txtXML := XMLTXT{
 		Name:            name,
 		UserData:        userData,
@@ -51,6 +67,7 @@ func (r *Root) ParseTXT(data []byte, sectionSize uint32) (*XMLTXT, error) {
 		StringLength:    text.StringLength,
 		MaxStringLength: text.MaxStringLength,
 		MatIndex:        text.MatIndex,
+		Font:            font,
 		StringOrigin:    text.StringOrigin,
 		LineAlignment:   text.LineAlignment,
 		XSize:           text.FontSizeX,
@@ -72,11 +89,14 @@ func (r *Root) ParseTXT(data []byte, sectionSize uint32) (*XMLTXT, error) {
 		Text: decodedString,
 	}
 
-	if r.HasChildren() {
-		txtXML.Children, err = r.ParseChildren()
-		if err != nil {
-			return nil, err
-		}
+	txtXML.Attached, err = r.parsePaneAttached()
+	if err != nil {
+		return nil, err
+	}
+
+	txtXML.Children, err = r.parseChildrenOf(name)
+	if err != nil {
+		return nil, err
 	}
 
 	return &txtXML, nil
@@ -96,7 +116,16 @@ func (b *BRLYTWriter) WriteTXT(txt XMLTXT) error {
 	var userData [8]byte
 	copy(userData[:], txt.UserData)
 
-	text := strings.Replace(txt.Text, "\\n", "\n", -1)
+	// Text panes without a font use the first one.
+	var fontIndex int
+	if txt.Font != "" {
+		fontIndex = slices.Index(b.fonts, txt.Font)
+		if fontIndex == -1 {
+			return ErrUnknownFont(txt.Name, txt.Font)
+		}
+	}
+
+	text := txt.text()
 	encodedText := utf16.Encode([]rune(text))
 
 	pane := TXT{
@@ -118,7 +147,7 @@ func (b *BRLYTWriter) WriteTXT(txt XMLTXT) error {
 		StringLength:    txt.StringLength,
 		MaxStringLength: txt.MaxStringLength,
 		MatIndex:        txt.MatIndex,
-		FontIndex:       0,
+		FontIndex:       uint16(fontIndex),
 		StringOrigin:    txt.StringOrigin,
 		LineAlignment:   txt.LineAlignment,
 		TextOffset:      116,
@@ -140,23 +169,15 @@ func (b *BRLYTWriter) WriteTXT(txt XMLTXT) error {
 		return err
 	}
 
-	err = write(temp, encodedText)
+	// Write the string with its null terminator
+	err = write(temp, append(encodedText, 0))
 	if err != nil {
 		return err
 	}
 
-	pos := 0
-	for (b.Len()+temp.Len())%4 != 0 {
+	// The string area is at least MaxStringLength bytes long and ends aligned.
+	for temp.Len()-int(pane.TextOffset) < int(txt.MaxStringLength) || (b.Len()+temp.Len())%4 != 0 {
 		temp.WriteByte(0)
-		pos += 1
-	}
-
-	// If there is no modulo padding, pad with an u32
-	if pos == 0 {
-		err = write(temp, uint32(0))
-		if err != nil {
-			return err
-		}
 	}
 
 	binary.BigEndian.PutUint32(temp.Bytes()[4:8], uint32(temp.Len()))
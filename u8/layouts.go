@@ -0,0 +1,37 @@
+package u8
+
+import (
+	"bytes"
+	"fmt"
+
+	brlyt "github.com/WiiLink24/brlytlib"
+)
+
+// Layouts parses every BRLYT in the archive, keyed by path.
+func (a *Archive) Layouts() (map[string]*brlyt.Root, error) {
+	layouts := map[string]*brlyt.Root{}
+	for _, entry := range a.Entries {
+		if entry.IsDir || !bytes.HasPrefix(entry.Data, []byte("RLYT")) {
+			continue
+		}
+
+		root, err := brlyt.ParseBRLYT(entry.Data)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", entry.Path, err)
+		}
+
+		layouts[entry.Path] = root
+	}
+
+	return layouts, nil
+}
+
+// OpenLayouts reads the U8 archive at name and parses every BRLYT in it, keyed by path.
+func OpenLayouts(name string) (map[string]*brlyt.Root, error) {
+	archive, err := Open(name)
+	if err != nil {
+		return nil, err
+	}
+
+	return archive.Layouts()
+}
@@ -0,0 +1,482 @@
+// Package u8 reads and writes U8 archives, which hold the layouts, animations,
+// textures and fonts of a banner or channel.
+package u8
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/WiiLink24/brlytlib/internal/binutil"
+	"github.com/WiiLink24/brlytlib/lz"
+)
+
+const magic = 0x55AA382D
+
+const (
+	nodeTypeFile uint8 = 0
+	nodeTypeDir  uint8 = 1
+)
+
+var (
+	ErrInvalidMagic = errors.New("file is not a U8 archive")
+	ErrOutOfBounds  = binutil.ErrOutOfBounds
+	ErrInvalidNode  = errors.New("archive node is invalid")
+	ErrNotFound     = errors.New("file does not exist in the archive")
+	ErrIsDirectory  = func(name string) error {
+		return fmt.Errorf("%s is a directory", name)
+	}
+)
+
+// Header represents the header of a U8 archive
+type Header struct {
+	Magic uint32
+	// RootOffset is the offset of the first node.
+	RootOffset uint32
+	// NodesSize is the size of the nodes and the string table following them.
+	NodesSize  uint32
+	DataOffset uint32
+	_          [16]byte
+}
+
+// Node is a file or directory in the archive.
+type Node struct {
+	Type uint8
+	// NameOffset is a 24 bit offset into the string table.
+	NameOffset [3]byte
+	// DataOffset is the absolute offset of a file's data, or the index of a directory's parent.
+	DataOffset uint32
+	// Size is the length of a file, or the index of the first node after a directory's contents.
+	Size uint32
+}
+
+// Archive is the contents of a U8 archive.
+type Archive struct {
+	// Entries are listed in archive order, with directories before their contents.
+	Entries []Entry
+	// Compression is applied to the whole archive when it is written. Parse sets it
+	// to the method the archive was compressed with.
+	Compression lz.Method
+}
+
+// Entry is a file or directory of an archive.
+type Entry struct {
+	// Path is the slash-separated path of the entry from the root of the archive.
+	Path  string
+	IsDir bool
+	Data  []byte
+}
+
+// Parse reads a U8 archive, decompressing it first if needed.
+func Parse(data []byte) (*Archive, error) {
+	var header Header
+	err := binutil.Read(data, 0, &header)
+	if err != nil {
+		return nil, err
+	}
+
+	compression := lz.None
+	if header.Magic != magic {
+		if lz.Detect(data) == lz.None {
+			return nil, ErrInvalidMagic
+		}
+
+		data, compression, err = lz.Decompress(data)
+		if err != nil {
+			return nil, err
+		}
+
+		err = binutil.Read(data, 0, &header)
+		if err != nil {
+			return nil, err
+		}
+
+		if header.Magic != magic {
+			return nil, ErrInvalidMagic
+		}
+	}
+
+	var root Node
+	err = binutil.Read(data, int(header.RootOffset), &root)
+	if err != nil {
+		return nil, err
+	}
+
+	// The root directory's size is the number of nodes.
+	nodeSize := binary.Size(root)
+	if root.Type != nodeTypeDir || root.Size == 0 || int64(root.Size)*int64(nodeSize) > int64(len(data)) {
+		return nil, ErrInvalidNode
+	}
+
+	nodes := make([]Node, root.Size)
+	err = binutil.Read(data, int(header.RootOffset), nodes)
+	if err != nil {
+		return nil, err
+	}
+
+	stringTable := int(header.RootOffset) + len(nodes)*nodeSize
+
+	// Nodes are stored depth first, so the enclosing directories are known when reaching a node.
+	archive := Archive{Compression: compression}
+	type dir struct {
+		path string
+		end  uint32
+	}
+	dirs := []dir{{path: "", end: root.Size}}
+	for i := 1; i < len(nodes); i++ {
+		node := nodes[i]
+		for len(dirs) > 1 && uint32(i) >= dirs[len(dirs)-1].end {
+			dirs = dirs[:len(dirs)-1]
+		}
+
+		nameOffset := int(node.NameOffset[0])<<16 | int(node.NameOffset[1])<<8 | int(node.NameOffset[2])
+		name, err := readString(data, stringTable+nameOffset)
+		if err != nil {
+			return nil, err
+		}
+
+		entryPath := joinPath(dirs[len(dirs)-1].path, name)
+		switch node.Type {
+		case nodeTypeDir:
+			if node.Size <= uint32(i) || node.Size > dirs[len(dirs)-1].end {
+				return nil, ErrInvalidNode
+			}
+
+			archive.Entries = append(archive.Entries, Entry{Path: entryPath, IsDir: true})
+			dirs = append(dirs, dir{path: entryPath, end: node.Size})
+		case nodeTypeFile:
+			start, end := int64(node.DataOffset), int64(node.DataOffset)+int64(node.Size)
+			if end > int64(len(data)) {
+				return nil, ErrOutOfBounds
+			}
+
+			archive.Entries = append(archive.Entries, Entry{
+				Path: entryPath,
+				Data: bytes.Clone(data[start:end]),
+			})
+		default:
+			return nil, ErrInvalidNode
+		}
+	}
+
+	return &archive, nil
+}
+
+// Open reads the U8 archive at name.
+func Open(name string) (*Archive, error) {
+	data, err := os.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+
+	return Parse(data)
+}
+
+// MarshalBinary encodes the archive, compressing it with Compression. Directories
+// that files are placed in do not have to be listed in Entries.
+func (a *Archive) MarshalBinary() ([]byte, error) {
+	entries := a.tree()
+
+	headerSize := binary.Size(Header{})
+	nodeSize := binary.Size(Node{})
+
+	// The root node has an empty name.
+	names := bytes.NewBuffer([]byte{0})
+	for _, entry := range entries {
+		names.WriteString(baseName(entry.Path))
+		names.WriteByte(0)
+	}
+
+	nodesSize := (len(entries)+1)*nodeSize + names.Len()
+	dataOffset := binutil.Align(headerSize+nodesSize, 32)
+
+	nodes := make([]Node, 0, len(entries)+1)
+	nodes = append(nodes, Node{Type: nodeTypeDir, Size: uint32(len(entries) + 1)})
+
+	contents := bytes.NewBuffer(nil)
+	nameOffset := 1
+	indices := map[string]int{"": 0}
+	for i, entry := range entries {
+		node := Node{
+			NameOffset: [3]byte{byte(nameOffset >> 16), byte(nameOffset >> 8), byte(nameOffset)},
+		}
+		nameOffset += len(baseName(entry.Path)) + 1
+
+		if entry.IsDir {
+			indices[entry.Path] = i + 1
+
+			// Contents directly follow the directory, so its end is after the last of them.
+			end := i + 1
+			for end < len(entries) && strings.HasPrefix(entries[end].Path, entry.Path+"/") {
+				end++
+			}
+
+			node.Type = nodeTypeDir
+			node.DataOffset = uint32(indices[parentPath(entry.Path)])
+			node.Size = uint32(end + 1)
+		} else {
+			node.Type = nodeTypeFile
+			node.DataOffset = uint32(dataOffset + contents.Len())
+			node.Size = uint32(len(entry.Data))
+
+			contents.Write(entry.Data)
+			binutil.PadTo(contents, 32)
+		}
+
+		nodes = append(nodes, node)
+	}
+
+	header := Header{
+		Magic:      magic,
+		RootOffset: uint32(headerSize),
+		NodesSize:  uint32(nodesSize),
+		DataOffset: uint32(dataOffset),
+	}
+
+	file := bytes.NewBuffer(nil)
+	for _, data := range []any{header, nodes, names.Bytes()} {
+		err := binary.Write(file, binary.BigEndian, data)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	binutil.PadTo(file, 32)
+	file.Write(contents.Bytes())
+	return lz.Compress(file.Bytes(), a.Compression)
+}
+
+// tree returns the entries ordered depth first, adding any directories that are
+// missing. Entries keep their relative order within a directory.
+func (a *Archive) tree() []Entry {
+	children := map[string][]Entry{}
+	seen := map[string]bool{"": true}
+
+	var add func(entry Entry)
+	add = func(entry Entry) {
+		if seen[entry.Path] {
+			return
+		}
+
+		parent := parentPath(entry.Path)
+		if !seen[parent] {
+			add(Entry{Path: parent, IsDir: true})
+		}
+
+		seen[entry.Path] = true
+		children[parent] = append(children[parent], entry)
+	}
+
+	for _, entry := range a.Entries {
+		entry.Path = cleanPath(entry.Path)
+		if entry.Path != "" {
+			add(entry)
+		}
+	}
+
+	var entries []Entry
+	var walk func(dir string)
+	walk = func(dir string) {
+		for _, entry := range children[dir] {
+			entries = append(entries, entry)
+			if entry.IsDir {
+				walk(entry.Path)
+			}
+		}
+	}
+
+	walk("")
+	return entries
+}
+
+// Files returns the paths of every file in the archive.
+func (a *Archive) Files() []string {
+	var files []string
+	for _, entry := range a.Entries {
+		if !entry.IsDir {
+			files = append(files, entry.Path)
+		}
+	}
+
+	return files
+}
+
+// File returns the contents of the file at name.
+func (a *Archive) File(name string) ([]byte, error) {
+	index := a.index(name)
+	if index == -1 {
+		return nil, ErrNotFound
+	}
+
+	if a.Entries[index].IsDir {
+		return nil, ErrIsDirectory(name)
+	}
+
+	return a.Entries[index].Data, nil
+}
+
+// Replace sets the contents of the existing file at name.
+func (a *Archive) Replace(name string, data []byte) error {
+	index := a.index(name)
+	if index == -1 {
+		return ErrNotFound
+	}
+
+	if a.Entries[index].IsDir {
+		return ErrIsDirectory(name)
+	}
+
+	a.Entries[index].Data = data
+	return nil
+}
+
+// Add creates the file at name, or replaces it if it exists. Missing
+// directories are created when the archive is written.
+func (a *Archive) Add(name string, data []byte) error {
+	err := a.Replace(name, data)
+	if errors.Is(err, ErrNotFound) {
+		a.Entries = append(a.Entries, Entry{Path: cleanPath(name), Data: data})
+		return nil
+	}
+
+	return err
+}
+
+// Remove deletes the file or directory at name, along with the directory's contents.
+func (a *Archive) Remove(name string) error {
+	if a.index(name) == -1 {
+		return ErrNotFound
+	}
+
+	key := pathKey(name)
+	a.Entries = slices.DeleteFunc(a.Entries, func(entry Entry) bool {
+		entryKey := pathKey(entry.Path)
+		return entryKey == key || key == "" || strings.HasPrefix(entryKey, key+"/")
+	})
+
+	return nil
+}
+
+// Extract writes the contents of the archive to dir.
+func (a *Archive) Extract(dir string) error {
+	for _, entry := range a.tree() {
+		target := filepath.Join(dir, filepath.FromSlash(entry.Path))
+		if !filepath.IsLocal(filepath.FromSlash(entry.Path)) {
+			return fmt.Errorf("archive path %q escapes the target directory", entry.Path)
+		}
+
+		if entry.IsDir {
+			err := os.MkdirAll(target, 0777)
+			if err != nil {
+				return err
+			}
+
+			continue
+		}
+
+		err := os.MkdirAll(filepath.Dir(target), 0777)
+		if err != nil {
+			return err
+		}
+
+		err = os.WriteFile(target, entry.Data, 0666)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// FromDirectory creates an archive from the contents of dir. Entries are sorted by name.
+func FromDirectory(dir string) (*Archive, error) {
+	var archive Archive
+	err := fs.WalkDir(os.DirFS(dir), ".", func(name string, d fs.DirEntry, err error) error {
+		if err != nil || name == "." {
+			return err
+		}
+
+		if d.IsDir() {
+			archive.Entries = append(archive.Entries, Entry{Path: name, IsDir: true})
+			return nil
+		}
+
+		data, err := os.ReadFile(filepath.Join(dir, filepath.FromSlash(name)))
+		if err != nil {
+			return err
+		}
+
+		archive.Entries = append(archive.Entries, Entry{Path: name, Data: data})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &archive, nil
+}
+
+func (a *Archive) index(name string) int {
+	key := pathKey(name)
+	return slices.IndexFunc(a.Entries, func(entry Entry) bool {
+		return pathKey(entry.Path) == key
+	})
+}
+
+// pathKey returns the form of an archive path that is compared when looking it up,
+// where "./arc" and "arc" are the same path and "." is the root itself.
+func pathKey(name string) string {
+	name = cleanPath(name)
+	if name == "." {
+		return ""
+	}
+
+	return strings.TrimPrefix(name, "./")
+}
+
+// cleanPath normalises an archive path, which never starts or ends with a slash.
+// Directories named "." are kept, as some archives place everything in one.
+func cleanPath(name string) string {
+	return strings.Trim(name, "/")
+}
+
+func joinPath(dir string, name string) string {
+	if dir == "" {
+		return name
+	}
+
+	return dir + "/" + name
+}
+
+func parentPath(name string) string {
+	index := strings.LastIndex(name, "/")
+	if index == -1 {
+		return ""
+	}
+
+	return name[:index]
+}
+
+func baseName(name string) string {
+	return name[strings.LastIndex(name, "/")+1:]
+}
+
+// readString reads a null terminated string starting at offset.
+func readString(data []byte, offset int) (string, error) {
+	if offset < 0 || offset >= len(data) {
+		return "", ErrOutOfBounds
+	}
+
+	end := bytes.IndexByte(data[offset:], 0)
+	if end == -1 {
+		return "", ErrOutOfBounds
+	}
+
+	return string(data[offset : offset+end]), nil
+}
@@ -0,0 +1,87 @@
+package u8
+
+import (
+	"errors"
+	"slices"
+	"testing"
+)
+
+func TestRemove(t *testing.T) {
+	tests := []struct {
+		name    string
+		entries []Entry
+		remove  string
+		want    []string
+	}{
+		{
+			name:    "file added with a leading dot",
+			entries: []Entry{{Path: "./arc/blyt/x.brlyt"}, {Path: "./arc/blyt/y.brlyt"}},
+			remove:  "arc/blyt/x.brlyt",
+			want:    []string{"./arc/blyt/y.brlyt"},
+		},
+		{
+			name:    "file removed with a leading dot",
+			entries: []Entry{{Path: "arc/blyt/x.brlyt"}, {Path: "arc/blyt/y.brlyt"}},
+			remove:  "./arc/blyt/x.brlyt",
+			want:    []string{"arc/blyt/y.brlyt"},
+		},
+		{
+			name: "directory and its contents",
+			entries: []Entry{
+				{Path: ".", IsDir: true},
+				{Path: "./arc", IsDir: true},
+				{Path: "./arc/blyt/x.brlyt"},
+				{Path: "./arcade.bin"},
+			},
+			remove: "arc",
+			want:   []string{".", "./arcade.bin"},
+		},
+		{
+			name:    "dot directory",
+			entries: []Entry{{Path: ".", IsDir: true}, {Path: "./arc/blyt/x.brlyt"}},
+			remove:  ".",
+			want:    nil,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			archive := Archive{Entries: test.entries}
+			err := archive.Remove(test.remove)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var paths []string
+			for _, entry := range archive.Entries {
+				paths = append(paths, entry.Path)
+			}
+
+			if !slices.Equal(paths, test.want) {
+				t.Errorf("got entries %q, want %q", paths, test.want)
+			}
+		})
+	}
+}
+
+func TestRemoveAfterAdd(t *testing.T) {
+	var archive Archive
+	err := archive.Add("./arc/blyt/x.brlyt", []byte("RLYT"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = archive.Remove("arc/blyt/x.brlyt")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(archive.Entries) != 0 {
+		t.Errorf("got entries %v, want none", archive.Entries)
+	}
+
+	err = archive.Remove("arc/blyt/x.brlyt")
+	if !errors.Is(err, ErrNotFound) {
+		t.Errorf("got error %v, want ErrNotFound", err)
+	}
+}
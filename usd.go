@@ -0,0 +1,229 @@
+package brlyt
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// USD represents the header of the usd1 section
+type USD struct {
+	NumOfEntries uint16
+	_            uint16
+}
+
+// USDEntry describes a single named value of a usd1 section.
+type USDEntry struct {
+	// NameOffset and DataOffset are relative to the beginning of the entry
+	NameOffset uint32
+	DataOffset uint32
+	Count      uint16
+	Type       uint8
+	_          uint8
+}
+
+// Types of values that can be stored in a usd1 entry.
+const (
+	USDTypeString uint8 = iota
+	USDTypeInt
+	USDTypeFloat
+)
+
+var usdTypeNames = []string{"string", "int", "float"}
+
+func (r *Root) ParseUSD(data []byte) (*XMLExtUserData, error) {
+	var usd USD
+	err := binary.Read(bytes.NewReader(data), binary.BigEndian, &usd)
+	if err != nil {
+		return nil, err
+	}
+
+	entries := make([]XMLUserDataEntry, usd.NumOfEntries)
+	for i := 0; i < int(usd.NumOfEntries); i++ {
+		offset := 4 + (i * 12)
+
+		var entry USDEntry
+		err = read(data, offset, &entry)
+		if err != nil {
+			return nil, err
+		}
+
+		if int(entry.Type) >= len(usdTypeNames) {
+			return nil, ErrInvalidUserDataType(entry.Type)
+		}
+
+		nameOffset := offset + int(entry.NameOffset)
+		err = checkBounds("user data name", nameOffset, 0, len(data))
+		if err != nil {
+			return nil, err
+		}
+
+		name, _, _ := strings.Cut(string(data[nameOffset:]), "\x00")
+		xmlEntry := XMLUserDataEntry{
+			Name: name,
+			Type: usdTypeNames[entry.Type],
+		}
+
+		dataOffset := offset + int(entry.DataOffset)
+		switch entry.Type {
+		case USDTypeString:
+			err = checkBounds("user data string", dataOffset, int(entry.Count), len(data))
+			if err != nil {
+				return nil, err
+			}
+
+			xmlEntry.String = strings.TrimRight(string(data[dataOffset:dataOffset+int(entry.Count)]), "\x00")
+		case USDTypeInt:
+			xmlEntry.Ints = make([]int32, entry.Count)
+			err = read(data, dataOffset, &xmlEntry.Ints)
+		case USDTypeFloat:
+			xmlEntry.Floats = make([]float32, entry.Count)
+			err = read(data, dataOffset, &xmlEntry.Floats)
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		entries[i] = xmlEntry
+	}
+
+	return &XMLExtUserData{Entries: entries}, nil
+}
+
+// parsePaneAttached consumes the sections directly following a pane, decoding the
+// usd1 section among them if there is one.
+func (r *Root) parsePaneAttached() ([]AttachedSection, error) {
+	sections, err := r.parseAttached()
+	if err != nil {
+		return nil, err
+	}
+
+	var attached []AttachedSection
+	for _, section := range sections {
+		if section.Magic != SectionTypeUSD.String() {
+			attached = append(attached, AttachedSection{Unknown: &section})
+			continue
+		}
+
+		userData, err := r.ParseUSD(section.Data)
+		if err != nil {
+			return nil, fmt.Errorf("usd1: %w", err)
+		}
+
+		attached = append(attached, AttachedSection{ExtUserData: userData})
+	}
+
+	return attached, nil
+}
+
+func (b *BRLYTWriter) WriteUSD(data XMLExtUserData) error {
+	temp := bytes.NewBuffer(nil)
+	values := bytes.NewBuffer(nil)
+	names := bytes.NewBuffer(nil)
+
+	header := SectionHeader{
+		Type: SectionTypeUSD,
+		Size: 0,
+	}
+
+	meta := USD{NumOfEntries: uint16(len(data.Entries))}
+
+	// Values follow the entry table and names follow the values.
+	tableSize := 12 + (12 * len(data.Entries))
+	entries := make([]USDEntry, len(data.Entries))
+	for i, entry := range data.Entries {
+		entryOffset := 12 + (12 * i)
+
+		valueType := slices.Index(usdTypeNames, entry.Type)
+		if valueType == -1 {
+			return ErrUnknownUserDataType(entry.Name, entry.Type)
+		}
+
+		entries[i] = USDEntry{
+			DataOffset: uint32(tableSize + values.Len() - entryOffset),
+			Type:       uint8(valueType),
+		}
+
+		switch uint8(valueType) {
+		case USDTypeString:
+			entries[i].Count = uint16(len(entry.String))
+			values.WriteString(entry.String)
+			values.WriteByte(0)
+			for values.Len()%4 != 0 {
+				values.WriteByte(0)
+			}
+		case USDTypeInt:
+			entries[i].Count = uint16(len(entry.Ints))
+			_ = write(values, entry.Ints)
+		case USDTypeFloat:
+			entries[i].Count = uint16(len(entry.Floats))
+			_ = write(values, entry.Floats)
+		}
+	}
+
+	for i, entry := range data.Entries {
+		entryOffset := 12 + (12 * i)
+		entries[i].NameOffset = uint32(tableSize + values.Len() + names.Len() - entryOffset)
+
+		names.WriteString(entry.Name)
+		names.WriteByte(0)
+	}
+
+	for (tableSize+values.Len()+names.Len())%4 != 0 {
+		names.WriteByte(0)
+	}
+
+	err := write(temp, header)
+	if err != nil {
+		return err
+	}
+
+	err = write(temp, meta)
+	if err != nil {
+		return err
+	}
+
+	err = write(temp, entries)
+	if err != nil {
+		return err
+	}
+
+	err = write(temp, values.Bytes())
+	if err != nil {
+		return err
+	}
+
+	err = write(temp, names.Bytes())
+	if err != nil {
+		return err
+	}
+
+	binary.BigEndian.PutUint32(temp.Bytes()[4:8], uint32(temp.Len()))
+	return write(b, temp.Bytes())
+}
+
+// writeAttached writes the sections following a pane in the order they are listed.
+func (b *BRLYTWriter) writeAttached(sections []AttachedSection) error {
+	for _, section := range sections {
+		var err error
+		switch {
+		case section.ExtUserData != nil:
+			err = b.WriteUSD(*section.ExtUserData)
+		case section.Unknown != nil:
+			err = b.WriteUnknown(*section.Unknown)
+		default:
+			continue
+		}
+
+		if err != nil {
+			return err
+		}
+
+		b.sectionCount++
+	}
+
+	return nil
+}
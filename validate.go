@@ -0,0 +1,183 @@
+package brlyt
+
+import (
+	"fmt"
+	"slices"
+)
+
+// Issue is a problem found when checking a layout, or an animation against its layout.
+type Issue struct {
+	// Entry is the name of the pane or material the issue concerns.
+	Entry string
+	// Tag is the animation tag the issue was found in, if any.
+	Tag     string
+	Message string
+}
+
+func (i Issue) String() string {
+	if i.Tag == "" {
+		return fmt.Sprintf("%s: %s", i.Entry, i.Message)
+	}
+
+	return fmt.Sprintf("%s (%s): %s", i.Entry, i.Tag, i.Message)
+}
+
+var (
+	paneTags     = []string{AnimationTagPaneSRT, AnimationTagVisibility, AnimationTagVertexColor}
+	materialTags = []string{AnimationTagMaterialColor, AnimationTagTextureSRT, AnimationTagIndirectSRT, AnimationTagTexturePattern}
+)
+
+// ValidateAnimation checks that everything anim refers to exists in layout and
+// that each animation tag suits the pane or material it is applied to.
+func ValidateAnimation(layout *Root, anim *Animation) []Issue {
+	var issues []Issue
+	report := func(entry string, tag string, format string, args ...any) {
+		issues = append(issues, Issue{Entry: entry, Tag: tag, Message: fmt.Sprintf(format, args...)})
+	}
+
+	// Keys past the end of the animation are never reached.
+	checkFrames := func(entry string, tag string, target XMLAnimationTarget) {
+		for _, key := range target.Keys {
+			if key.Frame > float32(anim.FrameSize) {
+				report(entry, tag, "key at frame %g is beyond the frame size of %d", key.Frame, anim.FrameSize)
+			}
+		}
+	}
+
+	var textures []string
+	if anim.Textures != nil {
+		textures = anim.Textures.TPLName
+	}
+
+	var layoutTextures []string
+	if layout.TXL != nil {
+		layoutTextures = layout.TXL.TPLName
+	}
+
+	for _, texture := range textures {
+		if !slices.Contains(layoutTextures, texture) {
+			report(texture, AnimationTagTexturePattern, "texture is not in txl1")
+		}
+	}
+
+	panes := layout.paneTypes()
+	for _, entry := range anim.Entries {
+		switch entry.Type {
+		case AnimationTypePane:
+			paneType, ok := panes[entry.Name]
+			if !ok {
+				report(entry.Name, "", "pane does not exist in the layout")
+				continue
+			}
+
+			for _, tag := range entry.Tags {
+				if !slices.Contains(paneTags, tag.Type) {
+					report(entry.Name, tag.Type, "tag cannot be applied to a pane")
+					continue
+				}
+
+				for _, target := range tag.Targets {
+					checkFrames(entry.Name, tag.Type, target)
+					switch tag.Type {
+					case AnimationTagPaneSRT:
+						if target.Target >= numPaneSRTTargets {
+							report(entry.Name, tag.Type, "unknown target %d", target.Target)
+						}
+					case AnimationTagVisibility:
+						if target.Target != 0 {
+							report(entry.Name, tag.Type, "unknown target %d", target.Target)
+						}
+					case AnimationTagVertexColor:
+						if target.Target > TargetPaneAlpha {
+							report(entry.Name, tag.Type, "unknown target %d", target.Target)
+						} else if target.Target != TargetPaneAlpha && (paneType == SectionTypePAN || paneType == SectionTypeBND) {
+							report(entry.Name, tag.Type, "%s pane has no vertex colours", paneType)
+						}
+					}
+				}
+			}
+		case AnimationTypeMaterial:
+			index := slices.IndexFunc(layout.MAT.Entries, func(mat MATEntries) bool {
+				return mat.Name == entry.Name
+			})
+
+			if index == -1 {
+				report(entry.Name, "", "material does not exist in the layout")
+				continue
+			}
+
+			material := layout.MAT.Entries[index]
+			for _, tag := range entry.Tags {
+				if !slices.Contains(materialTags, tag.Type) {
+					report(entry.Name, tag.Type, "tag cannot be applied to a material")
+					continue
+				}
+
+				for _, target := range tag.Targets {
+					checkFrames(entry.Name, tag.Type, target)
+					switch tag.Type {
+					case AnimationTagMaterialColor:
+						if target.Target >= numMaterialColorTargets {
+							report(entry.Name, tag.Type, "unknown target %d", target.Target)
+						}
+					case AnimationTagTextureSRT:
+						if target.Target >= numSRTTargets {
+							report(entry.Name, tag.Type, "unknown target %d", target.Target)
+						} else if int(target.Index) >= len(material.SRT) {
+							report(entry.Name, tag.Type, "texture SRT %d does not exist, the material has %d", target.Index, len(material.SRT))
+						}
+					case AnimationTagIndirectSRT:
+						if target.Target >= numSRTTargets {
+							report(entry.Name, tag.Type, "unknown target %d", target.Target)
+						} else if int(target.Index) >= len(material.IndirectSRT) {
+							report(entry.Name, tag.Type, "indirect SRT %d does not exist, the material has %d", target.Index, len(material.IndirectSRT))
+						}
+					case AnimationTagTexturePattern:
+						if int(target.Index) >= len(material.Textures) {
+							report(entry.Name, tag.Type, "texture %d does not exist, the material has %d", target.Index, len(material.Textures))
+						}
+
+						for _, key := range target.Keys {
+							if int(key.Value) >= len(textures) {
+								report(entry.Name, tag.Type, "key at frame %g uses image %d but only %d are listed", key.Frame, int(key.Value), len(textures))
+							}
+						}
+					}
+				}
+			}
+		default:
+			report(entry.Name, "", "unknown animation type %d", entry.Type)
+		}
+	}
+
+	return issues
+}
+
+// paneTypes returns the section type of every pane in the layout by name.
+func (r *Root) paneTypes() map[string]SectionTypes {
+	panes := map[string]SectionTypes{r.RootPane.Name: SectionTypePAN}
+	addPaneTypes(panes, r.RootPane.Children)
+	return panes
+}
+
+func addPaneTypes(panes map[string]SectionTypes, children []Children) {
+	for _, child := range children {
+		switch {
+		case child.Pane != nil:
+			panes[child.Pane.Name] = SectionTypePAN
+			addPaneTypes(panes, child.Pane.Children)
+		case child.BND != nil:
+			panes[child.BND.Name] = SectionTypeBND
+			addPaneTypes(panes, child.BND.Children)
+		case child.PIC != nil:
+			panes[child.PIC.Name] = SectionTypePIC
+			addPaneTypes(panes, child.PIC.Children)
+		case child.TXT != nil:
+			panes[child.TXT.Name] = SectionTypeTXT
+			addPaneTypes(panes, child.TXT.Children)
+		case child.WND != nil:
+			panes[child.WND.Name] = SectionTypeWND
+			addPaneTypes(panes, child.WND.Children)
+		}
+	}
+}
@@ -0,0 +1,110 @@
+package brlyt
+
+import (
+	"testing"
+)
+
+func TestValidateAnimation(t *testing.T) {
+	keys := []Keyframe{{Frame: 0, Value: 0}, {Frame: 60, Value: 1}}
+	entry := func(name string, entryType uint8, tag string, target uint8) AnimationEntry {
+		return AnimationEntry{Name: name, Type: entryType, Tags: []AnimationTag{{
+			Type:    tag,
+			Targets: []XMLAnimationTarget{{Target: target, CurveType: CurveTypeStep, Keys: keys}},
+		}}}
+	}
+
+	tests := []struct {
+		name  string
+		entry AnimationEntry
+		want  Issue
+	}{
+		{
+			name:  "unknown pane",
+			entry: entry("P_Missing", AnimationTypePane, AnimationTagPaneSRT, TargetTranslateX),
+			want:  Issue{Entry: "P_Missing", Message: "pane does not exist in the layout"},
+		},
+		{
+			name:  "unknown material",
+			entry: entry("M_Missing", AnimationTypeMaterial, AnimationTagMaterialColor, 0),
+			want:  Issue{Entry: "M_Missing", Message: "material does not exist in the layout"},
+		},
+		{
+			name:  "material tag on a pane",
+			entry: entry("P_Back", AnimationTypePane, AnimationTagTextureSRT, TargetSRTTranslateS),
+			want:  Issue{Entry: "P_Back", Tag: AnimationTagTextureSRT, Message: "tag cannot be applied to a pane"},
+		},
+		{
+			name:  "pane tag on a material",
+			entry: entry("M_Back", AnimationTypeMaterial, AnimationTagPaneSRT, TargetTranslateX),
+			want:  Issue{Entry: "M_Back", Tag: AnimationTagPaneSRT, Message: "tag cannot be applied to a material"},
+		},
+		{
+			name:  "vertex colour on a null pane",
+			entry: entry("N_Content", AnimationTypePane, AnimationTagVertexColor, 0),
+			want:  Issue{Entry: "N_Content", Tag: AnimationTagVertexColor, Message: "pan1 pane has no vertex colours"},
+		},
+		{
+			name:  "pane SRT target out of range",
+			entry: entry("P_Back", AnimationTypePane, AnimationTagPaneSRT, 10),
+			want:  Issue{Entry: "P_Back", Tag: AnimationTagPaneSRT, Message: "unknown target 10"},
+		},
+		{
+			name:  "material colour target out of range",
+			entry: entry("M_Back", AnimationTypeMaterial, AnimationTagMaterialColor, 32),
+			want:  Issue{Entry: "M_Back", Tag: AnimationTagMaterialColor, Message: "unknown target 32"},
+		},
+		{
+			name: "texture SRT out of range",
+			entry: AnimationEntry{Name: "M_Back", Type: AnimationTypeMaterial, Tags: []AnimationTag{{
+				Type:    AnimationTagTextureSRT,
+				Targets: []XMLAnimationTarget{{Index: 1, Target: TargetSRTRotate, CurveType: CurveTypeHermite, Keys: keys}},
+			}}},
+			want: Issue{Entry: "M_Back", Tag: AnimationTagTextureSRT, Message: "texture SRT 1 does not exist, the material has 1"},
+		},
+		{
+			name: "frame beyond the frame size",
+			entry: AnimationEntry{Name: "P_Back", Type: AnimationTypePane, Tags: []AnimationTag{{
+				Type: AnimationTagPaneSRT,
+				Targets: []XMLAnimationTarget{{Target: TargetTranslateX, CurveType: CurveTypeHermite, Keys: []Keyframe{
+					{Frame: 0, Value: 0},
+					{Frame: 150, Value: 1},
+				}}},
+			}}},
+			want: Issue{Entry: "P_Back", Tag: AnimationTagPaneSRT, Message: "key at frame 150 is beyond the frame size of 120"},
+		},
+		{
+			name:  "unknown animation type",
+			entry: entry("P_Back", 2, AnimationTagPaneSRT, TargetTranslateX),
+			want:  Issue{Entry: "P_Back", Message: "unknown animation type 2"},
+		},
+	}
+
+	layout := testLayout()
+	valid := &Animation{FrameSize: 120, Entries: []AnimationEntry{
+		entry("P_Back", AnimationTypePane, AnimationTagVertexColor, 0),
+		entry("M_Back", AnimationTypeMaterial, AnimationTagMaterialColor, 0),
+	}}
+
+	if issues := ValidateAnimation(layout, valid); len(issues) != 0 {
+		t.Fatalf("got issues %v for a valid animation, want none", issues)
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			anim := &Animation{FrameSize: 120, Entries: []AnimationEntry{test.entry}}
+			issues := ValidateAnimation(layout, anim)
+			if len(issues) != 1 || issues[0] != test.want {
+				t.Errorf("got issues %v, want [%v]", issues, test.want)
+			}
+		})
+	}
+
+	t.Run("texture not in txl1", func(t *testing.T) {
+		anim := &Animation{FrameSize: 120, Textures: &TPLNames{TPLName: []string{"back.tpl", "missing.tpl"}}}
+		want := Issue{Entry: "missing.tpl", Tag: AnimationTagTexturePattern, Message: "texture is not in txl1"}
+		issues := ValidateAnimation(layout, anim)
+		if len(issues) != 1 || issues[0] != want {
+			t.Errorf("got issues %v, want [%v]", issues, want)
+		}
+	})
+}
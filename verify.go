@@ -0,0 +1,182 @@
+package brlyt
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"reflect"
+)
+
+// ByteDiff describes a section that differs between a BRLYT and its rewritten form.
+type ByteDiff struct {
+	// Offset is the absolute offset of the first differing byte in the original file.
+	Offset int64
+	// Section is the type of the differing section, or RLYT for the file header.
+	Section SectionTypes
+	// Index is the position of the section in the file, or -1 for the file header.
+	Index int
+	// SectionOffset is the offset of the first differing byte relative to the section start.
+	SectionOffset int
+	// Field names the value containing the first differing byte, if it is known.
+	Field string
+	// Original and Written hold the differing bytes of that field in each file.
+	Original []byte
+	Written  []byte
+}
+
+func (d ByteDiff) String() string {
+	field := d.Field
+	if field == "" {
+		field = "contents"
+	}
+
+	return fmt.Sprintf("%s section %d at offset 0x%x (+0x%x, %s): original % x, written % x", d.Section, d.Index, d.Offset, d.SectionOffset, field, d.Original, d.Written)
+}
+
+// sectionStructs maps a section type to the structure at the start of its contents.
+var sectionStructs = map[SectionTypes]reflect.Type{
+	SectionTypeLYT: reflect.TypeOf(LYT{}),
+	SectionTypeTXL: reflect.TypeOf(TXL{}),
+	SectionTypeFNL: reflect.TypeOf(FNL{}),
+	SectionTypeMAT: reflect.TypeOf(MAT{}),
+	SectionTypePAN: reflect.TypeOf(Pane{}),
+	SectionTypeBND: reflect.TypeOf(Pane{}),
+	SectionTypePIC: reflect.TypeOf(PIC{}),
+	SectionTypeTXT: reflect.TypeOf(TXT{}),
+	SectionTypeWND: reflect.TypeOf(Window{}),
+	SectionTypeGRP: reflect.TypeOf(GRP{}),
+	SectionTypeUSD: reflect.TypeOf(USD{}),
+}
+
+// VerifyRoundTrip parses data, writes the result back and compares it with data.
+// Every section that differs is reported, in file order. No diffs means the
+// layout survives a round-trip byte-for-byte.
+func VerifyRoundTrip(data []byte) ([]ByteDiff, error) {
+	root, err := ParseBRLYT(data)
+	if err != nil {
+		return nil, err
+	}
+
+	written, err := root.MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+
+	if bytes.Equal(data, written) {
+		return nil, nil
+	}
+
+	headerLen := binary.Size(Header{})
+	var diffs []ByteDiff
+	if diff, ok := compareSection(data[:headerLen], written[:headerLen], reflect.TypeOf(Header{}), 0); ok {
+		diff.Section = headerMagic
+		diff.Index = -1
+		diffs = append(diffs, diff)
+	}
+
+	original := splitSections(data[headerLen:])
+	rewritten := splitSections(written[headerLen:])
+
+	offset := int64(headerLen)
+	for i := 0; i < max(len(original), len(rewritten)); i++ {
+		var a, b []byte
+		if i < len(original) {
+			a = original[i]
+		}
+		if i < len(rewritten) {
+			b = rewritten[i]
+		}
+
+		var section SectionTypes
+		if a != nil {
+			copy(section[:], a)
+		} else {
+			copy(section[:], b)
+		}
+
+		if diff, ok := compareSection(a, b, sectionStructs[section], 8); ok {
+			diff.Section = section
+			diff.Index = i
+			diff.Offset += offset
+			diffs = append(diffs, diff)
+		}
+
+		offset += int64(len(a))
+	}
+
+	return diffs, nil
+}
+
+// splitSections splits the contents of a BRLYT following its header into sections.
+func splitSections(data []byte) [][]byte {
+	var sections [][]byte
+	for len(data) >= 8 {
+		size := int(binary.BigEndian.Uint32(data[4:8]))
+		if size < 8 || size > len(data) {
+			size = len(data)
+		}
+
+		sections = append(sections, data[:size])
+		data = data[size:]
+	}
+
+	return sections
+}
+
+// compareSection finds the first byte that differs between a and b. The fields of
+// structure, which starts at start in the section, are used to name it.
+func compareSection(a, b []byte, structure reflect.Type, start int) (ByteDiff, bool) {
+	pos := 0
+	for pos < len(a) && pos < len(b) && a[pos] == b[pos] {
+		pos++
+	}
+
+	if pos == len(a) && pos == len(b) {
+		return ByteDiff{}, false
+	}
+
+	diff := ByteDiff{
+		Offset:        int64(pos),
+		SectionOffset: pos,
+	}
+
+	// Report the field the byte belongs to, or a short run of raw bytes past it.
+	fieldStart, fieldEnd := pos, pos+16
+	if pos < 8 && start == 8 {
+		diff.Field, fieldStart, fieldEnd = fieldAt(reflect.TypeOf(SectionHeader{}), pos)
+	} else if structure != nil {
+		name, from, to := fieldAt(structure, pos-start)
+		if name != "" {
+			diff.Field, fieldStart, fieldEnd = name, from+start, to+start
+		}
+	}
+
+	diff.Original = a[min(fieldStart, len(a)):min(fieldEnd, len(a))]
+	diff.Written = b[min(fieldStart, len(b)):min(fieldEnd, len(b))]
+	return diff, true
+}
+
+// fieldAt returns the name and byte range of the field of structure at offset.
+func fieldAt(structure reflect.Type, offset int) (string, int, int) {
+	if offset < 0 {
+		return "", 0, 0
+	}
+
+	pos := 0
+	for i := 0; i < structure.NumField(); i++ {
+		field := structure.Field(i)
+		size := binary.Size(reflect.Zero(field.Type).Interface())
+		if offset < pos+size {
+			name := field.Name
+			if name == "_" {
+				name = "padding"
+			}
+
+			return name, pos, pos + size
+		}
+
+		pos += size
+	}
+
+	return "", 0, 0
+}
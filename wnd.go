@@ -23,7 +23,7 @@ func (r *Root) ParseWND(data []byte) (*XMLWND, error) {
 		offset := 116 + (i * 32)
 
 		var uv UVSet
-		err = binary.Read(bytes.NewReader(data[offset:]), binary.BigEndian, &uv)
+		err = read(data, offset, &uv)
 		if err != nil {
 			return nil, err
 		}
@@ -56,13 +56,13 @@ func (r *Root) ParseWND(data []byte) (*XMLWND, error) {
 		offset := 116 + (int(wnd.NumOfUVSets) * 32) + (i * 4)
 
 		var actualOffset uint32
-		err = binary.Read(bytes.NewReader(data[offset:]), binary.BigEndian, &actualOffset)
+		err = read(data, offset, &actualOffset)
 		if err != nil {
 			return nil, err
 		}
 
 		var mat WindowMat
-		err = binary.Read(bytes.NewReader(data[actualOffset-8:]), binary.BigEndian, &mat)
+		err = read(data, int(actualOffset)-8, &mat)
 		if err != nil {
 			return nil, err
 		}
@@ -122,11 +122,14 @@ func (r *Root) ParseWND(data []byte) (*XMLWND, error) {
 		Materials: &XMLWindowMats{Mats: mats},
 	}
 
-	if r.HasChildren() {
-		xmlData.Children, err = r.ParseChildren()
-		if err != nil {
-			return nil, err
-		}
+	xmlData.Attached, err = r.parsePaneAttached()
+	if err != nil {
+		return nil, err
+	}
+
+	xmlData.Children, err = r.parseChildrenOf(name)
+	if err != nil {
+		return nil, err
 	}
 
 	return &xmlData, nil
@@ -135,6 +138,16 @@ func (r *Root) ParseWND(data []byte) (*XMLWND, error) {
 func (b *BRLYTWriter) WriteWND(data XMLWND) error {
 	temp := bytes.NewBuffer(nil)
 
+	var uvSets []XMLUVSet
+	if data.UVSets != nil {
+		uvSets = data.UVSets.Set
+	}
+
+	var mats []XMLWindowMat
+	if data.Materials != nil {
+		mats = data.Materials.Mats
+	}
+
 	header := SectionHeader{
 		Type: SectionTypeWND,
 		Size: 76,
@@ -166,15 +179,15 @@ func (b *BRLYTWriter) WriteWND(data XMLWND) error {
 		Coordinate2:       data.Coordinate2,
 		Coordinate3:       data.Coordinate3,
 		Coordinate4:       data.Coordinate4,
-		FrameCount:        uint8(len(data.Materials.Mats)),
+		FrameCount:        uint8(len(mats)),
 		WindowOffset:      104,
-		WindowFrameOffset: uint32(124 + len(data.UVSets.Set)*32),
+		WindowFrameOffset: uint32(124 + len(uvSets)*32),
 		TopLeftColor:      [4]uint8{data.TopLeftColor.R, data.TopLeftColor.G, data.TopLeftColor.B, data.TopLeftColor.A},
 		TopRightColor:     [4]uint8{data.TopRightColor.R, data.TopRightColor.G, data.TopRightColor.B, data.TopRightColor.A},
 		BottomLeftColor:   [4]uint8{data.BottomLeftColor.R, data.BottomLeftColor.G, data.BottomLeftColor.B, data.BottomLeftColor.A},
 		BottomRightColor:  [4]uint8{data.BottomRightColor.R, data.BottomRightColor.G, data.BottomRightColor.B, data.BottomRightColor.A},
 		MatIndex:          data.MatIndex,
-		NumOfUVSets:       uint8(len(data.UVSets.Set)),
+		NumOfUVSets:       uint8(len(uvSets)),
 	}
 
 	err := write(temp, header)
@@ -188,7 +201,7 @@ func (b *BRLYTWriter) WriteWND(data XMLWND) error {
 	}
 
 	// Write the UV Sets
-	for _, set := range data.UVSets.Set {
+	for _, set := range uvSets {
 		uvSet := UVSet{
 			TopLeftS:     set.CoordTL.S,
 			TopLeftT:     set.CoordTL.T,
@@ -207,10 +220,10 @@ func (b *BRLYTWriter) WriteWND(data XMLWND) error {
 	}
 
 	// Write the offsets to the Window Mats
-	for i := 0; i < len(data.Materials.Mats); i++ {
+	for i := 0; i < len(mats); i++ {
 		var offset uint32
 
-		offset = uint32(temp.Len() + (len(data.Materials.Mats) * 4))
+		offset = uint32(temp.Len() + (len(mats) * 4))
 
 		err = write(temp, offset)
 		if err != nil {
@@ -219,7 +232,7 @@ func (b *BRLYTWriter) WriteWND(data XMLWND) error {
 	}
 
 	// Write Window Mats
-	for _, mat := range data.Materials.Mats {
+	for _, mat := range mats {
 		windowMat := WindowMat{
 			MatIndex: mat.MatIndex,
 			Index:    mat.Index,
@@ -2,20 +2,28 @@ package brlyt
 
 import (
 	"bytes"
+	"encoding/base64"
 	"encoding/xml"
+	"strings"
 )
 
 type Root struct {
-	XMLName   xml.Name  `xml:"root"`
+	XMLName xml.Name `xml:"root"`
+	// Version is the format version from the file header. Zero means the usual version 10.
+	Version   uint16    `xml:"version,attr,omitempty"`
 	LYT       LYTNode   `xml:"lyt1"`
 	TXL       *TPLNames `xml:"txl1"`
 	FNL       *FNLNames `xml:"fnt1"`
 	MAT       MATNode   `xml:"mat1"`
 	RootPane  XMLPane   `xml:"pan1"`
 	RootGroup XMLGRP    `xml:"grp1"`
+	// Unknown holds unrecognised sections found between the top level sections.
+	Unknown []UnknownSection `xml:"unknown"`
 
 	reader *bytes.Reader
 	count  uint16
+	// path holds the names of the panes and groups enclosing the section being parsed.
+	path []string
 }
 
 // LYTNode specifies the values that LYT contains
@@ -39,6 +47,8 @@ type MATNode struct {
 	Entries []MATEntries `xml:"entries"`
 }
 
+// MATEntries is a material. Its BitFlag is derived from the entries when writing,
+// keeping only the bits that do not describe them.
 type MATEntries struct {
 	Name                 string                     `xml:"name,attr"`
 	ForeColor            Color16                    `xml:"foreColor"`
@@ -191,107 +201,161 @@ type Coord2D struct {
 }
 
 type Children struct {
-	Pane *XMLPane `xml:"pan1"`
-	GRP  *XMLGRP  `xml:"grp1"`
-	PIC  *XMLPIC  `xml:"pic1"`
-	TXT  *XMLTXT  `xml:"txt1"`
-	WND  *XMLWND  `xml:"wnd1"`
-	BND  *XMLPane `xml:"bnd1"`
+	Pane    *XMLPane        `xml:"pan1"`
+	GRP     *XMLGRP         `xml:"grp1"`
+	PIC     *XMLPIC         `xml:"pic1"`
+	TXT     *XMLTXT         `xml:"txt1"`
+	WND     *XMLWND         `xml:"wnd1"`
+	BND     *XMLPane        `xml:"bnd1"`
+	Unknown *UnknownSection `xml:"unknown"`
+}
+
+// AttachedSection is one of the sections directly following a pane, which are
+// kept in file order. The usd1 section is decoded, while any other is kept as-is.
+type AttachedSection struct {
+	ExtUserData *XMLExtUserData `xml:"user_data_ex"`
+	Unknown     *UnknownSection `xml:"unknown"`
+}
+
+// XMLExtUserData holds the named values of the usd1 section following a pane.
+type XMLExtUserData struct {
+	Entries []XMLUserDataEntry `xml:"entry"`
+}
+
+// XMLUserDataEntry is a single named value. Type is one of string, int or float
+// and selects which of the value fields is used.
+type XMLUserDataEntry struct {
+	Name   string    `xml:"name,attr"`
+	Type   string    `xml:"type,attr"`
+	String string    `xml:"string,omitempty"`
+	Ints   []int32   `xml:"int"`
+	Floats []float32 `xml:"float"`
+}
+
+// UnknownSection is a section that is not understood by this library. It is kept
+// as-is so that writing the layout back does not lose it.
+type UnknownSection struct {
+	Magic string `xml:"magic,attr"`
+	// After is the type of the top level section this one followed, if any.
+	After string     `xml:"after,attr,omitempty"`
+	Data  Base64Data `xml:",chardata"`
+}
+
+// Base64Data is binary data stored as base64 text in XML.
+type Base64Data []byte
+
+func (d Base64Data) MarshalText() ([]byte, error) {
+	return []byte(base64.StdEncoding.EncodeToString(d)), nil
+}
+
+func (d *Base64Data) UnmarshalText(text []byte) error {
+	data, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(text)))
+	if err != nil {
+		return err
+	}
+
+	*d = data
+	return nil
 }
 
 type XMLPane struct {
-	Name      string     `xml:"name,attr"`
-	UserData  string     `xml:"user_data,attr"`
-	Flag      uint8      `xml:"flag"`
-	Origin    Coord2D    `xml:"origin"`
-	Alpha     uint8      `xml:"alpha"`
-	Padding   uint8      `xml:"padding"`
-	Translate Coord3D    `xml:"translate"`
-	Rotate    Coord3D    `xml:"rotate"`
-	Scale     Coord2D    `xml:"scale"`
-	Width     float32    `xml:"width"`
-	Height    float32    `xml:"height"`
-	Children  []Children `xml:"children"`
+	Name      string            `xml:"name,attr"`
+	UserData  string            `xml:"user_data,attr"`
+	Flag      uint8             `xml:"flag"`
+	Origin    Coord2D           `xml:"origin"`
+	Alpha     uint8             `xml:"alpha"`
+	Padding   uint8             `xml:"padding"`
+	Translate Coord3D           `xml:"translate"`
+	Rotate    Coord3D           `xml:"rotate"`
+	Scale     Coord2D           `xml:"scale"`
+	Width     float32           `xml:"width"`
+	Height    float32           `xml:"height"`
+	Attached  []AttachedSection `xml:"attached"`
+	Children  []Children        `xml:"children"`
 }
 
 type XMLPIC struct {
-	Name             string     `xml:"name,attr"`
-	UserData         string     `xml:"user_data,attr"`
-	Visible          uint8      `xml:"visible"`
-	Widescreen       uint8      `xml:"widescreen_affected"`
-	Flag             uint8      `xml:"flag"`
-	Origin           Coord2D    `xml:"origin"`
-	Alpha            uint8      `xml:"alpha"`
-	Padding          uint8      `xml:"padding"`
-	Translate        Coord3D    `xml:"translate"`
-	Rotate           Coord3D    `xml:"rotate"`
-	Scale            Coord2D    `xml:"scale"`
-	Width            float32    `xml:"width"`
-	Height           float32    `xml:"height"`
-	TopLeftColor     Color8     `xml:"topLeftColor"`
-	TopRightColor    Color8     `xml:"topRightColor"`
-	BottomLeftColor  Color8     `xml:"bottomLeftColor"`
-	BottomRightColor Color8     `xml:"bottomRightColor"`
-	MatIndex         uint16     `xml:"matIndex"`
-	UVSets           *XMLUVSets `xml:"uv_sets"`
-	Children         []Children `xml:"children"`
+	Name             string            `xml:"name,attr"`
+	UserData         string            `xml:"user_data,attr"`
+	Visible          uint8             `xml:"visible"`
+	Widescreen       uint8             `xml:"widescreen_affected"`
+	Flag             uint8             `xml:"flag"`
+	Origin           Coord2D           `xml:"origin"`
+	Alpha            uint8             `xml:"alpha"`
+	Padding          uint8             `xml:"padding"`
+	Translate        Coord3D           `xml:"translate"`
+	Rotate           Coord3D           `xml:"rotate"`
+	Scale            Coord2D           `xml:"scale"`
+	Width            float32           `xml:"width"`
+	Height           float32           `xml:"height"`
+	TopLeftColor     Color8            `xml:"topLeftColor"`
+	TopRightColor    Color8            `xml:"topRightColor"`
+	BottomLeftColor  Color8            `xml:"bottomLeftColor"`
+	BottomRightColor Color8            `xml:"bottomRightColor"`
+	MatIndex         uint16            `xml:"matIndex"`
+	UVSets           *XMLUVSets        `xml:"uv_sets"`
+	Attached         []AttachedSection `xml:"attached"`
+	Children         []Children        `xml:"children"`
 }
 
 type XMLTXT struct {
-	Name            string     `xml:"name,attr"`
-	UserData        string     `xml:"user_data,attr"`
-	Visible         uint8      `xml:"visible"`
-	Widescreen      uint8      `xml:"widescreen_affected"`
-	Flag            uint8      `xml:"flag"`
-	Origin          Coord2D    `xml:"origin"`
-	Alpha           uint8      `xml:"alpha"`
-	Padding         uint8      `xml:"padding"`
-	Translate       Coord3D    `xml:"translate"`
-	Rotate          Coord3D    `xml:"rotate"`
-	Scale           Coord2D    `xml:"scale"`
-	Width           float32    `xml:"width"`
-	Height          float32    `xml:"height"`
-	StringLength    uint16     `xml:"string_length"`
-	MaxStringLength uint16     `xml:"max_string_length"`
-	MatIndex        uint16     `xml:"matIndex"`
-	StringOrigin    uint8      `xml:"string_origin"`
-	LineAlignment   uint8      `xml:"line_alignment"`
-	XSize           float32    `xml:"x_size"`
-	YSize           float32    `xml:"y_size"`
-	CharSize        float32    `xml:"charsize"`
-	LineSize        float32    `xml:"linesize"`
-	TopColor        Color8     `xml:"top_color"`
-	BottomColor     Color8     `xml:"bottom_color"`
-	Text            string     `xml:"text"`
-	Children        []Children `xml:"children"`
+	Name            string            `xml:"name,attr"`
+	UserData        string            `xml:"user_data,attr"`
+	Visible         uint8             `xml:"visible"`
+	Widescreen      uint8             `xml:"widescreen_affected"`
+	Flag            uint8             `xml:"flag"`
+	Origin          Coord2D           `xml:"origin"`
+	Alpha           uint8             `xml:"alpha"`
+	Padding         uint8             `xml:"padding"`
+	Translate       Coord3D           `xml:"translate"`
+	Rotate          Coord3D           `xml:"rotate"`
+	Scale           Coord2D           `xml:"scale"`
+	Width           float32           `xml:"width"`
+	Height          float32           `xml:"height"`
+	StringLength    uint16            `xml:"string_length"`
+	MaxStringLength uint16            `xml:"max_string_length"`
+	MatIndex        uint16            `xml:"matIndex"`
+	Font            string            `xml:"font,omitempty"`
+	StringOrigin    uint8             `xml:"string_origin"`
+	LineAlignment   uint8             `xml:"line_alignment"`
+	XSize           float32           `xml:"x_size"`
+	YSize           float32           `xml:"y_size"`
+	CharSize        float32           `xml:"charsize"`
+	LineSize        float32           `xml:"linesize"`
+	TopColor        Color8            `xml:"top_color"`
+	BottomColor     Color8            `xml:"bottom_color"`
+	Text            string            `xml:"text"`
+	Attached        []AttachedSection `xml:"attached"`
+	Children        []Children        `xml:"children"`
 }
 
 type XMLWND struct {
-	Name             string         `xml:"name,attr"`
-	UserData         string         `xml:"user_data,attr"`
-	Visible          uint8          `xml:"visible"`
-	Widescreen       uint8          `xml:"widescreen_affected"`
-	Flag             uint8          `xml:"flag"`
-	Origin           Coord2D        `xml:"origin"`
-	Alpha            uint8          `xml:"alpha"`
-	Padding          uint8          `xml:"padding"`
-	Translate        Coord3D        `xml:"translate"`
-	Rotate           Coord3D        `xml:"rotate"`
-	Scale            Coord2D        `xml:"scale"`
-	Width            float32        `xml:"width"`
-	Height           float32        `xml:"height"`
-	Coordinate1      float32        `xml:"coordinate_1"`
-	Coordinate2      float32        `xml:"coordinate_2"`
-	Coordinate3      float32        `xml:"coordinate_3"`
-	Coordinate4      float32        `xml:"coordinate_4"`
-	TopLeftColor     Color8         `xml:"topLeftColor"`
-	TopRightColor    Color8         `xml:"topRightColor"`
-	BottomLeftColor  Color8         `xml:"bottomLeftColor"`
-	BottomRightColor Color8         `xml:"bottomRightColor"`
-	MatIndex         uint16         `xml:"matIndex"`
-	UVSets           *XMLUVSets     `xml:"uv_sets"`
-	Materials        *XMLWindowMats `xml:"materials"`
-	Children         []Children     `xml:"children"`
+	Name             string            `xml:"name,attr"`
+	UserData         string            `xml:"user_data,attr"`
+	Visible          uint8             `xml:"visible"`
+	Widescreen       uint8             `xml:"widescreen_affected"`
+	Flag             uint8             `xml:"flag"`
+	Origin           Coord2D           `xml:"origin"`
+	Alpha            uint8             `xml:"alpha"`
+	Padding          uint8             `xml:"padding"`
+	Translate        Coord3D           `xml:"translate"`
+	Rotate           Coord3D           `xml:"rotate"`
+	Scale            Coord2D           `xml:"scale"`
+	Width            float32           `xml:"width"`
+	Height           float32           `xml:"height"`
+	Coordinate1      float32           `xml:"coordinate_1"`
+	Coordinate2      float32           `xml:"coordinate_2"`
+	Coordinate3      float32           `xml:"coordinate_3"`
+	Coordinate4      float32           `xml:"coordinate_4"`
+	TopLeftColor     Color8            `xml:"topLeftColor"`
+	TopRightColor    Color8            `xml:"topRightColor"`
+	BottomLeftColor  Color8            `xml:"bottomLeftColor"`
+	BottomRightColor Color8            `xml:"bottomRightColor"`
+	MatIndex         uint16            `xml:"matIndex"`
+	UVSets           *XMLUVSets        `xml:"uv_sets"`
+	Materials        *XMLWindowMats    `xml:"materials"`
+	Attached         []AttachedSection `xml:"attached"`
+	Children         []Children        `xml:"children"`
 }
 
 type XMLWindowMat struct {
@@ -320,7 +384,47 @@ type STCoordinates struct {
 }
 
 type XMLGRP struct {
-	Name     string     `xml:"name,attr"`
-	Entries  []string   `xml:"entries"`
-	Children []Children `xml:"children"`
+	Name     string           `xml:"name,attr"`
+	Entries  []string         `xml:"entries"`
+	Unknown  []UnknownSection `xml:"unknown"`
+	Children []Children       `xml:"children"`
+}
+
+// Animation is the XML representation of a BRLAN.
+type Animation struct {
+	XMLName xml.Name `xml:"animation"`
+	// Version is the format version from the file header. Zero means the usual version 10.
+	Version   uint16 `xml:"version,attr,omitempty"`
+	FrameSize uint16 `xml:"frame_size"`
+	Loop      uint8  `xml:"loop"`
+	// Textures are the images that texture pattern animations switch between.
+	Textures *TPLNames        `xml:"timg"`
+	Entries  []AnimationEntry `xml:"entry"`
+	Unknown  []UnknownSection `xml:"unknown"`
+}
+
+// AnimationEntry holds the animations of a single pane or material.
+type AnimationEntry struct {
+	Name string         `xml:"name,attr"`
+	Type uint8          `xml:"type,attr"`
+	Tags []AnimationTag `xml:"tag"`
+}
+
+type AnimationTag struct {
+	Type    string               `xml:"type,attr"`
+	Targets []XMLAnimationTarget `xml:"target"`
+}
+
+type XMLAnimationTarget struct {
+	Index     uint8      `xml:"index,attr"`
+	Target    uint8      `xml:"target,attr"`
+	CurveType uint8      `xml:"curve_type,attr"`
+	Keys      []Keyframe `xml:"key"`
+}
+
+// Keyframe is a key of an animation curve. Slope is only used by Hermite curves.
+type Keyframe struct {
+	Frame float32 `xml:"frame,attr"`
+	Value float32 `xml:"value,attr"`
+	Slope float32 `xml:"slope,attr,omitempty"`
 }